@@ -0,0 +1,43 @@
+package v1alpha1
+
+const (
+	// RequestedAtAnnotation on a Yago requests a fresh fetch and apply whenever its value changes
+	RequestedAtAnnotation = "yago.aerdei.com/requestedAt"
+	// SyncWaveAnnotation on a manifest sets the wave it is applied in, waves are applied in
+	// ascending order and default to 0
+	SyncWaveAnnotation = "yago.aerdei.com/sync-wave"
+	// HookAnnotation on a Job manifest makes it a hook run in the given HookPhase instead of
+	// a regular object
+	HookAnnotation = "yago.aerdei.com/hook"
+	// HookDeletePolicyAnnotation on a hook sets when its Job is deleted, as a comma separated
+	// list of delete policies. It defaults to BeforeHookCreation
+	HookDeletePolicyAnnotation = "yago.aerdei.com/hook-delete-policy"
+	// InstanceAnnotation on an object is the namespace/name of the Yago managing it
+	InstanceAnnotation = "yago.aerdei.com/instance-name"
+	// IgnoreDifferencesAnnotation on a manifest is a comma separated list of JSON pointers to
+	// fields that are neither compared with nor patched onto the live object
+	IgnoreDifferencesAnnotation = "yago.aerdei.com/ignore-differences"
+	// SyncOptionsAnnotation on a manifest is a comma separated list of Option=value sync options
+	// changing how its object is updated
+	SyncOptionsAnnotation = "yago.aerdei.com/sync-options"
+)
+
+const (
+	// SyncOptionReplace set to true replaces the object instead of merge patching its spec
+	SyncOptionReplace = "Replace"
+	// SyncOptionRecreate set to true recreates the object when an update changes immutable
+	// fields even without forceUpdate, set to never it is not recreated even with forceUpdate
+	SyncOptionRecreate = "Recreate"
+)
+
+// InstanceLabel on an object is the UID of the Yago managing it
+const InstanceLabel = "yago.aerdei.com/instance"
+
+const (
+	// DeletePolicyBeforeHookCreation deletes the Job of the previous run before running a hook
+	DeletePolicyBeforeHookCreation = "BeforeHookCreation"
+	// DeletePolicyHookSucceeded deletes the Job once the hook succeeded
+	DeletePolicyHookSucceeded = "HookSucceeded"
+	// DeletePolicyHookFailed deletes the Job once the hook failed
+	DeletePolicyHookFailed = "HookFailed"
+)
@@ -0,0 +1,71 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionType is the type of a Yago condition
+type ConditionType string
+
+const (
+	// ConditionSuspended is true while spec.suspend keeps the Yago from syncing
+	ConditionSuspended ConditionType = "Suspended"
+	// ConditionHealthy is true once every object applied from the current commit is healthy
+	ConditionHealthy ConditionType = "Healthy"
+	// ConditionSynced is true once the last sync attempt succeeded, its message holds the error otherwise
+	ConditionSynced ConditionType = "Synced"
+	// ConditionConflict is true while objects of the repository are skipped because another Yago manages them
+	ConditionConflict ConditionType = "Conflict"
+	// ConditionVerificationFailed is true while the fetched commit is refused for not being signed by a trusted key
+	ConditionVerificationFailed ConditionType = "VerificationFailed"
+)
+
+// Condition describes the state of a Yago at a certain point
+type Condition struct {
+	Type   ConditionType          `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// GetCondition returns the condition of the given type, nil if it is not set
+func (s *YagoStatus) GetCondition(t ConditionType) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == t {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the given type. The transition
+// time is only moved when the status of the condition changes.
+func (s *YagoStatus) SetCondition(t ConditionType, status corev1.ConditionStatus, reason, message string) {
+	if c := s.GetCondition(t); c != nil {
+		if c.Status != status {
+			c.LastTransitionTime = metav1.Now()
+		}
+		c.Status = status
+		c.Reason = reason
+		c.Message = message
+		return
+	}
+	s.Conditions = append(s.Conditions, Condition{
+		Type:               t,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	})
+}
+
+// IsConditionTrue reports whether the condition of the given type is set to true
+func (s *YagoStatus) IsConditionTrue(t ConditionType) bool {
+	c := s.GetCondition(t)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
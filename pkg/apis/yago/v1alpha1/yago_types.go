@@ -16,6 +16,188 @@ type YagoSpec struct {
 	// +optional
 	BranchReference string `json:"branchReference"`
 	ForceUpdate     bool   `json:"forceUpdate"`
+	// Suspend stops the Yago from fetching and applying the repository
+	// while leaving the managed objects in place
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// SyncWindows restrict when new commits are applied
+	// +optional
+	SyncWindows []SyncWindow `json:"syncWindows,omitempty"`
+	// Rollback re-applies the last healthy commit when a new commit does not become healthy in time
+	// +optional
+	Rollback *RollbackSpec `json:"rollback,omitempty"`
+	// HistoryLimit is the number of sync attempts kept in the status, 10 if unset
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+	// DeletionPolicy tells what happens to the managed objects when the Yago is deleted,
+	// Delete if unset
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Adopt tells whether existing objects not controlled by this Yago are taken over, Never if unset
+	// +kubebuilder:validation:Enum=Never;IfUnowned;Always
+	// +optional
+	Adopt AdoptionPolicy `json:"adopt,omitempty"`
+	// IgnoreDifferences lists fields of matching objects that are neither compared nor patched
+	// +optional
+	IgnoreDifferences []ResourceIgnoreDifferences `json:"ignoreDifferences,omitempty"`
+	// TargetNamespace is the namespace objects are applied in, the namespace of the Yago if unset
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// AllowedNamespaces lists the namespaces manifests may put their objects in through
+	// metadata.namespace, other namespaces are replaced by the target namespace
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+	// ServiceAccountName is the ServiceAccount in the namespace of the Yago that managed objects are
+	// read and written as, the operator's own ServiceAccount if unset
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// KubeConfig points to the kubeconfig of a remote cluster the objects are applied to, the
+	// cluster of the operator if unset
+	// +optional
+	KubeConfig *KubeConfigSpec `json:"kubeConfig,omitempty"`
+	// AllowedKinds are the kinds the repository may contain, within those the operator allows,
+	// every kind the operator allows if empty
+	// +optional
+	AllowedKinds []KindPattern `json:"allowedKinds,omitempty"`
+	// DeniedKinds are the kinds the repository may not contain, besides those the operator denies
+	// +optional
+	DeniedKinds []KindPattern `json:"deniedKinds,omitempty"`
+	// PolicyConfigMaps are ConfigMaps in the namespace of the Yago whose keys hold the policies
+	// every object is checked against before anything is applied
+	// +optional
+	PolicyConfigMaps []string `json:"policyConfigMaps,omitempty"`
+	// Atomic applies a commit only if a server-side dry run of every object succeeds
+	// +optional
+	Atomic bool `json:"atomic,omitempty"`
+	// Verify makes only commits signed by trusted OpenPGP keys be applied
+	// +optional
+	Verify *VerifySpec `json:"verify,omitempty"`
+	// Git configures how the repository is fetched
+	// +optional
+	Git *GitSpec `json:"git,omitempty"`
+}
+
+// GitSpec configures how the repository is fetched
+type GitSpec struct {
+	// Depth is the number of commits fetched from the tip of the branch, the whole history if unset
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Depth int32 `json:"depth,omitempty"`
+}
+
+// KindPattern matches objects by API group and kind, both of which may be glob patterns such as *
+type KindPattern struct {
+	// Group of the objects, empty for the core group
+	// +optional
+	Group string `json:"group,omitempty"`
+	// Kind of the objects
+	Kind string `json:"kind"`
+}
+
+// KubeConfigSpec points to the kubeconfig of a remote cluster
+type KubeConfigSpec struct {
+	// SecretRef is the Secret in the namespace of the Yago holding the kubeconfig
+	SecretRef KubeConfigSecretRef `json:"secretRef"`
+}
+
+// KubeConfigSecretRef selects the kubeconfig in a Secret
+type KubeConfigSecretRef struct {
+	// Name of the Secret
+	Name string `json:"name"`
+	// Key of the kubeconfig in the Secret, kubeconfig if unset
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// VerifySpec points to the OpenPGP public keys commits must be signed with
+type VerifySpec struct {
+	// SecretRef is the Secret in the namespace of the Yago holding the armored public keys
+	SecretRef VerifySecretRef `json:"secretRef"`
+}
+
+// VerifySecretRef selects the trusted keys in a Secret
+type VerifySecretRef struct {
+	// Name of the Secret
+	Name string `json:"name"`
+	// Key of the armored public keys in the Secret, the keys of every entry are trusted if unset
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ResourceIgnoreDifferences excludes fields of the objects matching its group, kind and name
+// from comparison and patching
+type ResourceIgnoreDifferences struct {
+	// Group of the objects, empty for the core group
+	// +optional
+	Group string `json:"group,omitempty"`
+	// Kind of the objects
+	Kind string `json:"kind"`
+	// Name of the object, every object of the kind if unset
+	// +optional
+	Name string `json:"name,omitempty"`
+	// JSONPointers are RFC 6901 pointers to the ignored fields
+	// +optional
+	JSONPointers []string `json:"jsonPointers,omitempty"`
+	// JSONPaths are JSONPath expressions of the ignored fields, supporting field names, list
+	// indexes, wildcards and equality filters
+	// +optional
+	JSONPaths []string `json:"jsonPaths,omitempty"`
+}
+
+// AdoptionPolicy tells whether existing objects are taken over by a Yago
+type AdoptionPolicy string
+
+const (
+	// AdoptNever updates existing objects without taking them over
+	AdoptNever AdoptionPolicy = "Never"
+	// AdoptIfUnowned takes over existing objects that have no controller
+	AdoptIfUnowned AdoptionPolicy = "IfUnowned"
+	// AdoptAlways takes over existing objects unless they are controlled by another Yago
+	AdoptAlways AdoptionPolicy = "Always"
+)
+
+// DeletionPolicy tells what happens to the managed objects when the Yago is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete garbage collects the managed objects along with the Yago
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan releases the managed objects and leaves them in place
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// Finalizer keeps a Yago around until its deletion policy is carried out
+const Finalizer = "yago.aerdei.com/finalizer"
+
+// RollbackSpec configures automatic rollbacks
+type RollbackSpec struct {
+	// Timeout is how long a new commit may stay unhealthy before it is rolled back, e.g. 10m
+	Timeout string `json:"timeout"`
+}
+
+// SyncWindowKind tells whether a sync window allows or denies syncing
+type SyncWindowKind string
+
+const (
+	// SyncWindowAllow windows are the only periods new commits are applied in
+	SyncWindowAllow SyncWindowKind = "allow"
+	// SyncWindowDeny windows block applying new commits, overriding allow windows
+	SyncWindowDeny SyncWindowKind = "deny"
+)
+
+// SyncWindow is a recurring period in which applying new commits is allowed or denied
+type SyncWindow struct {
+	// +kubebuilder:validation:Enum=allow;deny
+	Kind SyncWindowKind `json:"kind"`
+	// Schedule is a five field cron expression marking the start of the window
+	Schedule string `json:"schedule"`
+	// Duration of the window, e.g. 1h30m
+	Duration string `json:"duration"`
+	// TimeZone the schedule is evaluated in, UTC if empty
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 // YagoStatus defines the observed state of Yago
@@ -24,6 +206,162 @@ type YagoStatus struct {
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
 	CurrentCommit string `json:"currentCommit"`
+	// AppliedAt is when the current commit was first applied
+	// +optional
+	AppliedAt *metav1.Time `json:"appliedAt,omitempty"`
+	// LastHealthyCommit is the last commit every object was healthy at
+	// +optional
+	LastHealthyCommit string `json:"lastHealthyCommit,omitempty"`
+	// RejectedCommit was rolled back and is not applied again until a newer commit arrives
+	// +optional
+	RejectedCommit string `json:"rejectedCommit,omitempty"`
+	// LastHandledRequest is the value of the requestedAt annotation that was last synced
+	// +optional
+	LastHandledRequest string `json:"lastHandledRequest,omitempty"`
+	// PendingCommit is a fetched commit waiting for a sync window to be applied
+	// +optional
+	PendingCommit string `json:"pendingCommit,omitempty"`
+	// NextSyncWindow is the closest upcoming sync window
+	// +optional
+	NextSyncWindow *SyncWindowStatus `json:"nextSyncWindow,omitempty"`
+	// SyncWave is the wave that is being applied, or was applied last
+	// +optional
+	SyncWave *int32 `json:"syncWave,omitempty"`
+	// Inventory lists the objects applied from the current commit and their health
+	// +optional
+	Inventory []ManagedObject `json:"inventory,omitempty"`
+	// Hooks are the outcomes of the hooks run for the current commit
+	// +optional
+	Hooks []HookStatus `json:"hooks,omitempty"`
+	// History of the latest sync attempts, newest first
+	// +optional
+	History []SyncRecord `json:"history,omitempty"`
+	// PolicyViolations of the last commit checked against the policies
+	// +optional
+	PolicyViolations []PolicyViolation `json:"policyViolations,omitempty"`
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// PolicyMode tells what a policy violation does to the sync
+type PolicyMode string
+
+const (
+	// PolicyEnforce violations fail the sync before anything is applied
+	PolicyEnforce PolicyMode = "enforce"
+	// PolicyWarn violations are only reported
+	PolicyWarn PolicyMode = "warn"
+)
+
+// PolicyViolation is an object of the repository breaking a policy
+type PolicyViolation struct {
+	Policy string     `json:"policy"`
+	Mode   PolicyMode `json:"mode"`
+	// Path of the file of the object in the repository
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// SyncWindowStatus is a single occurrence of a sync window
+type SyncWindowStatus struct {
+	Kind  SyncWindowKind `json:"kind"`
+	Start metav1.Time    `json:"start"`
+	End   metav1.Time    `json:"end"`
+}
+
+// HealthStatus is the health of an object managed by a Yago
+type HealthStatus string
+
+const (
+	// HealthHealthy objects have reached their desired state
+	HealthHealthy HealthStatus = "Healthy"
+	// HealthProgressing objects are still working towards their desired state
+	HealthProgressing HealthStatus = "Progressing"
+	// HealthDegraded objects failed to reach their desired state
+	HealthDegraded HealthStatus = "Degraded"
+	// HealthMissing objects do not exist in the cluster
+	HealthMissing HealthStatus = "Missing"
+)
+
+// ManagedObject is an object applied from the repository
+type ManagedObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Namespace of the object, empty for cluster-scoped objects
+	// +optional
+	Namespace string       `json:"namespace,omitempty"`
+	Name      string       `json:"name"`
+	Health    HealthStatus `json:"health"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// HookPhase is the phase of a sync a hook runs in
+type HookPhase string
+
+const (
+	// HookPreSync hooks run before any object of a commit is applied
+	HookPreSync HookPhase = "PreSync"
+	// HookPostSync hooks run once every object of a commit is applied and healthy
+	HookPostSync HookPhase = "PostSync"
+	// HookSyncFail hooks run when a sync or another hook fails
+	HookSyncFail HookPhase = "SyncFail"
+)
+
+// HookResult is the outcome of a hook
+type HookResult string
+
+const (
+	// HookResultSucceeded hooks completed successfully
+	HookResultSucceeded HookResult = "Succeeded"
+	// HookResultFailed hooks failed or timed out
+	HookResultFailed HookResult = "Failed"
+	// HookResultRunning hooks have a Job that has not finished yet
+	HookResultRunning HookResult = "Running"
+)
+
+// HookStatus is the outcome of a hook run for a commit
+type HookStatus struct {
+	Name   string    `json:"name"`
+	Phase  HookPhase `json:"phase"`
+	Commit string    `json:"commit"`
+	// Request is the requestedAt annotation of the sync the hook ran in
+	// +optional
+	Request string     `json:"request,omitempty"`
+	Result  HookResult `json:"result"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// SyncOutcome is the result of a sync attempt
+type SyncOutcome string
+
+const (
+	// SyncSucceeded attempts applied every object of the commit
+	SyncSucceeded SyncOutcome = "Succeeded"
+	// SyncFailed attempts stopped on an error
+	SyncFailed SyncOutcome = "Failed"
+	// SyncInProgress attempts are waiting for the cluster, such as for a wave to become ready,
+	// and carry on in a later attempt
+	SyncInProgress SyncOutcome = "InProgress"
+)
+
+// SyncRecord describes a sync attempt
+type SyncRecord struct {
+	Commit string `json:"commit"`
+	// +optional
+	CommitMessage string `json:"commitMessage,omitempty"`
+	// +optional
+	Author     string      `json:"author,omitempty"`
+	StartedAt  metav1.Time `json:"startedAt"`
+	FinishedAt metav1.Time `json:"finishedAt"`
+	Outcome    SyncOutcome `json:"outcome"`
+	// +optional
+	Error   string `json:"error,omitempty"`
+	Created int32  `json:"created"`
+	Updated int32  `json:"updated"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
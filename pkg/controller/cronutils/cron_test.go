@@ -0,0 +1,122 @@
+package cronutils
+
+import (
+	"testing"
+	"time"
+)
+
+func bitsOf(values ...uint) uint64 {
+	var bits uint64
+	for _, v := range values {
+		bits |= 1 << v
+	}
+	return bits
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		field   string
+		b       bounds
+		want    uint64
+		wantErr bool
+	}{
+		{field: "5", b: minuteBounds, want: bitsOf(5)},
+		{field: "*", b: hourBounds, want: 1<<24 - 1},
+		{field: "1-3", b: domBounds, want: bitsOf(1, 2, 3)},
+		{field: "1,15,30", b: minuteBounds, want: bitsOf(1, 15, 30)},
+		{field: "*/15", b: minuteBounds, want: bitsOf(0, 15, 30, 45)},
+		{field: "10-20/5", b: minuteBounds, want: bitsOf(10, 15, 20)},
+		{field: "50/5", b: minuteBounds, want: bitsOf(50, 55)},
+		{field: "1-2,*/6", b: hourBounds, want: bitsOf(0, 1, 2, 6, 12, 18)},
+		{field: "60", b: minuteBounds, wantErr: true},
+		{field: "0", b: domBounds, wantErr: true},
+		{field: "5-1", b: hourBounds, wantErr: true},
+		{field: "*/0", b: minuteBounds, wantErr: true},
+		{field: "a", b: minuteBounds, wantErr: true},
+		{field: "1-x", b: minuteBounds, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseField(tt.field, tt.b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseField(%q) error = %v, wantErr %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseField(%q) = %b, want %b", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec             string
+		domStar, dowStar bool
+		wantErr          bool
+	}{
+		{spec: "* * * * *", domStar: true, dowStar: true},
+		{spec: "0 0 */2 * *", domStar: true, dowStar: true},
+		{spec: "0 0 1 * */2", domStar: false, dowStar: true},
+		{spec: "0 0 1,15 * 1-5", domStar: false, dowStar: false},
+		{spec: "0 0 * *", wantErr: true},
+		{spec: "0 0 * * * *", wantErr: true},
+		{spec: "0 24 * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if s.domStar != tt.domStar || s.dowStar != tt.dowStar {
+			t.Errorf("Parse(%q) domStar, dowStar = %v, %v, want %v, %v", tt.spec, s.domStar, s.dowStar, tt.domStar, tt.dowStar)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2020-01-01 was a Wednesday
+	start := time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{spec: "* * * * *", from: start, want: time.Date(2020, 1, 1, 10, 31, 0, 0, time.UTC)},
+		{spec: "30 10 * * *", from: start, want: time.Date(2020, 1, 2, 10, 30, 0, 0, time.UTC)},
+		{spec: "*/20 * * * *", from: start, want: time.Date(2020, 1, 1, 10, 40, 0, 0, time.UTC)},
+		{spec: "0 9-17 * * *", from: start, want: time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{spec: "0 0 1 * *", from: start, want: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * 3 *", from: start, want: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		// Saturday
+		{spec: "0 0 * * 6", from: start, want: time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC)},
+		// Both 0 and 7 are Sunday
+		{spec: "0 0 * * 0", from: start, want: time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", from: start, want: time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		// Monday to Friday
+		{spec: "0 8 * * 1-5", from: time.Date(2020, 1, 3, 9, 0, 0, 0, time.UTC), want: time.Date(2020, 1, 6, 8, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches, the 10th or a Monday
+		{spec: "0 0 10 * 1", from: start, want: time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 10 * 1", from: time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC), want: time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)},
+		// A stepped day field is a star, so both day fields must match: a Monday on an odd day
+		{spec: "0 0 */2 * 1", from: start, want: time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC)},
+		// A stepped day of week is a star too: the 5th must fall on a Sunday, Tuesday, Thursday or Saturday
+		{spec: "0 0 5 * */2", from: start, want: time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 5 * */2", from: time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC), want: time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 6 * */2", from: start, want: time.Date(2020, 2, 6, 0, 0, 0, 0, time.UTC)},
+		// February 30th never comes
+		{spec: "0 0 30 2 *", from: start, want: time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q, %s) = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package gitutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Repository cache, shared by every Yago of the same repository
+var (
+	cacheDir = flag.String("repo-cache-dir", "",
+		"Directory repositories are kept in between syncs and fetched incrementally, every sync clones into memory if empty")
+	cacheMaxSize = flag.String("repo-cache-max-size", "1Gi",
+		"Size the repository cache is kept under by removing the least recently used repositories")
+)
+
+// Fetches of the same repository are serialized
+var (
+	locksLock sync.Mutex
+	repoLocks = map[string]*sync.Mutex{}
+)
+
+func repoLock(key string) *sync.Mutex {
+	locksLock.Lock()
+	defer locksLock.Unlock()
+	if _, ok := repoLocks[key]; !ok {
+		repoLocks[key] = &sync.Mutex{}
+	}
+	return repoLocks[key]
+}
+
+// The size and last use of every cached repository, read from disk once and then kept up to date
+// after each fetch, and how many callers are reading each. Repositories in use are not removed.
+var (
+	gcLock    sync.Mutex
+	repoSizes map[string]int64
+	repoUsed  map[string]time.Time
+	repoUsers = map[string]int{}
+)
+
+// acquire keeps the repository of key from being removed and fetched by others until the
+// returned func is called
+func acquire(key string) func() {
+	gcLock.Lock()
+	repoUsers[key]++
+	gcLock.Unlock()
+	lock := repoLock(key)
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		gcLock.Lock()
+		repoUsers[key]--
+		if repoUsers[key] == 0 {
+			delete(repoUsers, key)
+		}
+		gcLock.Unlock()
+	}
+}
+
+// cacheKey is the directory of the repository of url in the cache, which holds the branch as deep
+// as it was ever fetched
+func cacheKey(url string, refName plumbing.ReferenceName) string {
+	sum := sha256.Sum256([]byte(url + "#" + refName.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// fetchCached fetches the branch into the cached repository of url, only downloading the objects
+// it does not have yet, and returns the repository and the reference of the branch. The repository
+// stays as it is until release is called, which must be once it is no longer read.
+func fetchCached(url string, refName plumbing.ReferenceName, depth int) (r *git.Repository, ref *plumbing.Reference, release func(), err error) {
+	key := cacheKey(url, refName)
+	release = acquire(key)
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	path := filepath.Join(*cacheDir, key)
+	r, err = openCached(path, url)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	remoteName := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, refName.Short())
+	err = r.Fetch(&git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", refName, remoteName))},
+		Depth:    depth,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, nil, nil, err
+	}
+	remoteRef, err := r.Reference(remoteName, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// The modification time of the directory tells the garbage collector when it was used last,
+	// after a restart
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return nil, nil, nil, err
+	}
+	size, err := dirSize(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := collectGarbage(key, size, now); err != nil {
+		return nil, nil, nil, err
+	}
+	return r, plumbing.NewHashReference(refName, remoteRef.Hash()), release, nil
+}
+
+// openCached opens the bare repository at path, creating it if it does not exist or cannot be read
+func openCached(path string, url string) (*git.Repository, error) {
+	r, err := git.PlainOpen(path)
+	if err == nil {
+		return r, nil
+	}
+	if err != git.ErrRepositoryNotExists {
+		// Left behind half written, most likely by a restart during the first fetch
+		if err := os.RemoveAll(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	r, err = git.PlainInit(path, true)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// collectGarbage records the size and last use of the repository of key, then removes the least
+// recently used repositories not in use until the cache fits its maximum size
+func collectGarbage(key string, size int64, usedAt time.Time) error {
+	maxSize, err := resource.ParseQuantity(*cacheMaxSize)
+	if err != nil {
+		return fmt.Errorf("invalid repository cache size %q: %v", *cacheMaxSize, err)
+	}
+	gcLock.Lock()
+	defer gcLock.Unlock()
+
+	if repoSizes == nil {
+		if err := loadCacheState(); err != nil {
+			return err
+		}
+	}
+	repoSizes[key], repoUsed[key] = size, usedAt
+	var total int64
+	keys := make([]string, 0, len(repoSizes))
+	for k, size := range repoSizes {
+		total += size
+		keys = append(keys, k)
+	}
+	if total <= maxSize.Value() {
+		return nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return repoUsed[keys[i]].Before(repoUsed[keys[j]])
+	})
+	for _, k := range keys {
+		if total <= maxSize.Value() {
+			break
+		}
+		if repoUsers[k] > 0 {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(*cacheDir, k)); err != nil {
+			return err
+		}
+		total -= repoSizes[k]
+		delete(repoSizes, k)
+		delete(repoUsed, k)
+	}
+	return nil
+}
+
+// loadCacheState reads the size and last use of the repositories left in the cache directory by
+// a previous run
+func loadCacheState() error {
+	entries, err := ioutil.ReadDir(*cacheDir)
+	if err != nil {
+		return err
+	}
+	sizes, used := map[string]int64{}, map[string]time.Time{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		size, err := dirSize(filepath.Join(*cacheDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		sizes[entry.Name()], used[entry.Name()] = size, entry.ModTime()
+	}
+	repoSizes, repoUsed = sizes, used
+	return nil
+}
+
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		// Temporary files of a concurrent fetch may be gone already
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
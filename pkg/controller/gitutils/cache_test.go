@@ -0,0 +1,272 @@
+package gitutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func tempDir(t *testing.T, prefix string) string {
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// sourceRepo is a repository to fetch from, in a local directory removed by close
+type sourceRepo struct {
+	t    *testing.T
+	dir  string
+	repo *git.Repository
+}
+
+func newSourceRepo(t *testing.T) *sourceRepo {
+	dir := tempDir(t, "yago-source")
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sourceRepo{t: t, dir: dir, repo: r}
+}
+
+func (s *sourceRepo) close() {
+	os.RemoveAll(s.dir)
+}
+
+// commit writes the file and commits it on master
+func (s *sourceRepo) commit(name, content string) plumbing.Hash {
+	if err := ioutil.WriteFile(filepath.Join(s.dir, name), []byte(content), 0644); err != nil {
+		s.t.Fatal(err)
+	}
+	w, err := s.repo.Worktree()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if _, err := w.Add(name); err != nil {
+		s.t.Fatal(err)
+	}
+	hash, err := w.Commit("Change "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return hash
+}
+
+// withCache points the repository cache at a new directory until restore is called
+func withCache(t *testing.T, maxSize string) (dir string, restore func()) {
+	dir = tempDir(t, "yago-cache")
+	oldDir, oldMaxSize := *cacheDir, *cacheMaxSize
+	*cacheDir, *cacheMaxSize = dir, maxSize
+	gcLock.Lock()
+	repoSizes, repoUsed = nil, nil
+	gcLock.Unlock()
+	return dir, func() {
+		*cacheDir, *cacheMaxSize = oldDir, oldMaxSize
+		gcLock.Lock()
+		repoSizes, repoUsed = nil, nil
+		gcLock.Unlock()
+		os.RemoveAll(dir)
+	}
+}
+
+func readFile(t *testing.T, tree *object.Tree, name string) string {
+	file, err := tree.File(name)
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	return content
+}
+
+func TestHandleRepoCached(t *testing.T) {
+	cache, restore := withCache(t, "1Gi")
+	defer restore()
+	source := newSourceRepo(t)
+	defer source.close()
+	first := source.commit("a.yaml", "a: 1\n")
+
+	ref, _, tree, err := HandleRepo(source.dir, "master", 0, nil)
+	if err != nil {
+		t.Fatalf("HandleRepo() error = %v", err)
+	}
+	if ref.Hash() != first {
+		t.Errorf("HandleRepo() ref = %s, want %s", ref.Hash(), first)
+	}
+	second := source.commit("b.yaml", "b: 2\n")
+	ref, commit, tree, err := HandleRepo(source.dir, "master", 0, nil)
+	if err != nil {
+		t.Fatalf("HandleRepo() error = %v", err)
+	}
+	if ref.Hash() != second || commit.Hash != second {
+		t.Errorf("HandleRepo() ref = %s, commit = %s, want %s", ref.Hash(), commit.Hash, second)
+	}
+	entries, err := ioutil.ReadDir(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("cache has %d repositories, want 1", len(entries))
+	}
+	// The tree is read from memory, even once the repository is gone from the cache
+	if err := os.RemoveAll(filepath.Join(cache, entries[0].Name())); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, tree, "b.yaml"); got != "b: 2\n" {
+		t.Errorf("b.yaml = %q", got)
+	}
+	if got := readFile(t, tree, "a.yaml"); got != "a: 1\n" {
+		t.Errorf("a.yaml = %q", got)
+	}
+	if commit.Message != "Change b.yaml" {
+		t.Errorf("commit message = %q", commit.Message)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	// Small enough to fit a single repository only
+	cache, restore := withCache(t, "1")
+	defer restore()
+	older, newer, inUse := newSourceRepo(t), newSourceRepo(t), newSourceRepo(t)
+	defer older.close()
+	defer newer.close()
+	defer inUse.close()
+	older.commit("a.yaml", "a: 1\n")
+	newer.commit("b.yaml", "b: 2\n")
+	inUse.commit("c.yaml", "c: 3\n")
+	exists := func(url string) bool {
+		_, err := os.Stat(filepath.Join(cache, cacheKey(url, plumbing.Master)))
+		return err == nil
+	}
+
+	if _, _, _, err := HandleRepo(older.dir, "master", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	// The repository just fetched stays, even if it alone is over the size
+	if !exists(older.dir) {
+		t.Errorf("repository just fetched was removed")
+	}
+	if _, _, _, err := HandleRepo(newer.dir, "master", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if exists(older.dir) || !exists(newer.dir) {
+		t.Errorf("least recently used repository was not the one removed")
+	}
+
+	// A repository being read is not removed
+	_, _, release, err := fetchCached(inUse.dir, plumbing.Master, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := HandleRepo(older.dir, "master", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(inUse.dir) {
+		t.Errorf("repository in use was removed")
+	}
+	if exists(newer.dir) {
+		t.Errorf("repository not in use was kept")
+	}
+	release()
+	if _, _, _, err := HandleRepo(newer.dir, "master", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if exists(inUse.dir) {
+		t.Errorf("released repository was kept")
+	}
+
+	// Sizes are kept track of without reading the cache directory again
+	gcLock.Lock()
+	defer gcLock.Unlock()
+	if len(repoSizes) != 1 || repoSizes[cacheKey(newer.dir, plumbing.Master)] == 0 {
+		t.Errorf("repository sizes = %v, want only the one of the last fetch", repoSizes)
+	}
+}
+
+func TestHandleCommitDeepens(t *testing.T) {
+	source := newSourceRepo(t)
+	defer source.close()
+	var history []plumbing.Hash
+	for i := 0; i < 30; i++ {
+		history = append(history, source.commit("a.yaml", fmt.Sprintf("a: %d\n", i)))
+	}
+
+	tests := []struct {
+		name      string
+		cached    bool
+		headDepth int
+		depth     int
+		commit    int
+	}{
+		{name: "in memory", headDepth: 1, depth: 1, commit: 25},
+		{name: "cached within the second deepening", cached: true, headDepth: 1, depth: 1, commit: 25},
+		{name: "cached down to the first commit", cached: true, headDepth: 1, depth: 1, commit: 0},
+		{name: "whole history of a shallow cached repository", cached: true, headDepth: 5, depth: 0, commit: 0},
+	}
+	for _, tt := range tests {
+		func() {
+			var cache string
+			if tt.cached {
+				var restore func()
+				cache, restore = withCache(t, "1Gi")
+				defer restore()
+				if _, _, _, err := HandleRepo(source.dir, "master", tt.headDepth, nil); err != nil {
+					t.Fatalf("%s: HandleRepo() error = %v", tt.name, err)
+				}
+			}
+			commit, tree, err := HandleCommit(source.dir, "master", tt.depth, history[tt.commit], nil)
+			if err != nil {
+				t.Errorf("%s: HandleCommit() error = %v", tt.name, err)
+				return
+			}
+			if commit.Hash != history[tt.commit] {
+				t.Errorf("%s: HandleCommit() commit = %s, want %s", tt.name, commit.Hash, history[tt.commit])
+			}
+			if got, want := readFile(t, tree, "a.yaml"), fmt.Sprintf("a: %d\n", tt.commit); got != want {
+				t.Errorf("%s: a.yaml = %q, want %q", tt.name, got, want)
+			}
+			if !tt.cached {
+				return
+			}
+			// The cached repository was deepened instead of fetched again next to it
+			entries, err := ioutil.ReadDir(cache)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("%s: cache has %d repositories, want 1", tt.name, len(entries))
+			}
+			r, err := git.PlainOpen(filepath.Join(cache, cacheKey(source.dir, plumbing.Master)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			shallows, err := r.Storer.Shallow()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, h := range shallows {
+				for _, newer := range history[tt.commit+1:] {
+					if h == newer {
+						t.Errorf("%s: history is still cut off at %s", tt.name, h)
+					}
+				}
+			}
+			// The deepened history is there for the next caller without deepening again
+			if _, err := r.CommitObject(history[tt.commit]); err != nil {
+				t.Errorf("%s: deepened commit is not in the cached repository: %v", tt.name, err)
+			}
+		}()
+	}
+}
@@ -1,45 +1,321 @@
 package gitutils
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"strings"
 
 	git "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
-//HandleRepo returns a ref, object tree, if all are handled. Error otherwise
-func HandleRepo(url string, branch string) (*plumbing.Reference, *object.Tree, error) {
-	r, err := cloneRepo(url, branch)
+//VerificationError is returned for commits that are not signed by any of the trusted keys
+type VerificationError struct {
+	Hash plumbing.Hash
+	Err  error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("commit %s is not signed by a trusted key: %v", e.Hash, e.Err)
+}
+
+//maxShallowDepth is the deepest shallow fetch tried for a commit before the whole history is fetched
+const maxShallowDepth = 1000
+
+//HandleRepo returns a ref, commit, object tree, if all are handled. Error otherwise.
+//Only depth commits are fetched from the tip of the branch, the whole history if depth is 0.
+//If there are trusted keys, the head commit or an annotated tag of it must be signed by one of them.
+//The commit and tree are held in memory, apart from the repository.
+func HandleRepo(url string, branch string, depth int, trustedKeys []string) (*plumbing.Reference, *object.Commit, *object.Tree, error) {
+	r, ref, release, err := openRepo(url, branch, depth)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer release()
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := verifyCommit(r, commit, trustedKeys); err != nil {
+		return nil, nil, nil, err
+	}
+	commit, tree, err := detach(r.Storer, commit)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ref, commit, tree, err
+}
+
+//HandleCommit returns a commit of the branch and its object tree, if all are handled. Error otherwise.
+//If the commit is older than the history fetched, the history is deepened until it is found.
+//If there are trusted keys, the commit is verified like the head commit of HandleRepo.
+func HandleCommit(url string, branch string, depth int, hash plumbing.Hash, trustedKeys []string) (*object.Commit, *object.Tree, error) {
+	r, ref, release, err := openRepo(url, branch, depth)
 	if err != nil {
 		return nil, nil, err
 	}
-	ref, err := r.Head()
+	defer release()
+	commit, err := r.CommitObject(hash)
+	for err == plumbing.ErrObjectNotFound {
+		shallows, shallowErr := r.Storer.Shallow()
+		if shallowErr != nil {
+			return nil, nil, shallowErr
+		}
+		if len(shallows) == 0 {
+			break
+		}
+		depth = deeper(depth)
+		if err := deepen(r, url, ref.Hash(), shallows, depth); err != nil {
+			return nil, nil, err
+		}
+		commit, err = r.CommitObject(hash)
+		if depth == 0 {
+			break
+		}
+	}
 	if err != nil {
 		return nil, nil, err
 	}
-	commit, err := r.CommitObject(ref.Hash())
+	if err := verifyCommit(r, commit, trustedKeys); err != nil {
+		return nil, nil, err
+	}
+	return detach(r.Storer, commit)
+}
+
+//detach copies the commit and its tree into memory, so that they can still be read once the
+//repository is released, and without keeping the rest of the repository around.
+func detach(s storer.EncodedObjectStorer, commit *object.Commit) (*object.Commit, *object.Tree, error) {
+	mem := memory.NewStorage()
+	if err := copyObject(s, mem, commit.Hash); err != nil {
+		return nil, nil, err
+	}
+	if err := copyTree(s, mem, commit.TreeHash); err != nil {
+		return nil, nil, err
+	}
+	detached, err := object.GetCommit(mem, commit.Hash)
 	if err != nil {
 		return nil, nil, err
 	}
-	tree, err := commit.Tree()
+	tree, err := detached.Tree()
 	if err != nil {
 		return nil, nil, err
 	}
-	return ref, tree, err
+	return detached, tree, nil
+}
+
+//copyTree copies a tree with its subtrees and files. Submodules are left out, as they are not
+//part of the repository.
+func copyTree(from, to storer.EncodedObjectStorer, hash plumbing.Hash) error {
+	if err := copyObject(from, to, hash); err != nil {
+		return err
+	}
+	tree, err := object.GetTree(from, hash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries {
+		switch entry.Mode {
+		case filemode.Submodule:
+		case filemode.Dir:
+			if err := copyTree(from, to, entry.Hash); err != nil {
+				return err
+			}
+		default:
+			if err := copyObject(from, to, entry.Hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func copyObject(from, to storer.EncodedObjectStorer, hash plumbing.Hash) error {
+	obj, err := from.EncodedObject(plumbing.AnyObject, hash)
+	if err != nil {
+		return err
+	}
+	copied := to.NewEncodedObject()
+	copied.SetType(obj.Type())
+	copied.SetSize(obj.Size())
+	reader, err := obj.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	writer, err := copied.Writer()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	_, err = to.SetEncodedObject(copied)
+	return err
 }
 
-func cloneRepo(url string, branch string) (*git.Repository, error) {
-	var refName plumbing.ReferenceName
+//verifyCommit checks the signature of the commit, or of an annotated tag pointing to it, against
+//each of the armored key rings. Every commit is accepted if there are none.
+func verifyCommit(r *git.Repository, commit *object.Commit, trustedKeys []string) error {
+	if len(trustedKeys) == 0 {
+		return nil
+	}
+	verr := errors.New("commit is not signed")
+	if commit.PGPSignature != "" {
+		for _, keyRing := range trustedKeys {
+			if _, verr = commit.Verify(keyRing); verr == nil {
+				return nil
+			}
+		}
+	}
+	tags, err := r.TagObjects()
+	if err != nil {
+		return err
+	}
+	verified := false
+	err = tags.ForEach(func(tag *object.Tag) error {
+		if tag.Target != commit.Hash || tag.PGPSignature == "" {
+			return nil
+		}
+		for _, keyRing := range trustedKeys {
+			if _, err := tag.Verify(keyRing); err == nil {
+				verified = true
+				return storer.ErrStop
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return &VerificationError{Hash: commit.Hash, Err: verr}
+	}
+	return nil
+}
+
+//openRepo returns the repository of url with the branch fetched, and the reference of the branch.
+//The repository is kept in the cache directory if there is one, cloned into memory otherwise.
+//release must be called once the repository is no longer read.
+func openRepo(url string, branch string, depth int) (*git.Repository, *plumbing.Reference, func(), error) {
+	refName := branchReferenceName(branch)
+	if *cacheDir != "" {
+		return fetchCached(url, refName, depth)
+	}
+	r, err := cloneRepo(url, refName, depth)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return r, ref, func() {}, nil
+}
+
+//deeper is the depth to deepen the history to when a commit is not within depth
+func deeper(depth int) int {
+	if depth*10 > maxShallowDepth {
+		return 0
+	}
+	return depth * 10
+}
+
+//deepen fetches the history of head down to depth commits, the whole history if depth is 0, into
+//the shallow repository r. go-git only fetches commits it does not have and never tells the server
+//where the history it has is cut off, so the request is made here instead. The commits within
+//depth are sent again, as the server cannot tell which of them are there already.
+func deepen(r *git.Repository, url string, head plumbing.Hash, shallows []plumbing.Hash, depth int) (err error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return err
+	}
+	c, err := client.NewClient(ep)
+	if err != nil {
+		return err
+	}
+	s, err := c.NewUploadPackSession(ep, nil)
+	if err != nil {
+		return err
+	}
+	defer ioutil.CheckClose(s, &err)
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		return err
+	}
+	req := packp.NewUploadPackRequestFromCapabilities(ar.Capabilities)
+	req.Capabilities.Delete(capability.ThinPack)
+	if err := req.Capabilities.Set(capability.Shallow); err != nil {
+		return err
+	}
+	if depth == 0 {
+		// What git asks for to unshallow a repository
+		depth = math.MaxInt32
+	}
+	req.Depth = packp.DepthCommits(depth)
+	req.Wants = []plumbing.Hash{head}
+	req.Shallows = shallows
+	resp, err := s.UploadPack(context.TODO(), req)
+	if err != nil {
+		return err
+	}
+	defer ioutil.CheckClose(resp, &err)
+
+	var reader io.Reader = resp
+	switch {
+	case req.Capabilities.Supports(capability.Sideband64k):
+		reader = sideband.NewDemuxer(sideband.Sideband64k, resp)
+	case req.Capabilities.Supports(capability.Sideband):
+		reader = sideband.NewDemuxer(sideband.Sideband, resp)
+	}
+	if err := packfile.UpdateObjectStorage(r.Storer, reader); err != nil {
+		return err
+	}
+	// The history is now cut off at the new shallow commits instead of the old ones
+	unshallowed := map[plumbing.Hash]bool{}
+	for _, h := range resp.Unshallows {
+		unshallowed[h] = true
+	}
+	var updated []plumbing.Hash
+	for _, h := range append(shallows, resp.Shallows...) {
+		if !unshallowed[h] {
+			unshallowed[h] = true
+			updated = append(updated, h)
+		}
+	}
+	return r.Storer.SetShallow(updated)
+}
+
+func branchReferenceName(branch string) plumbing.ReferenceName {
 	if strings.ToLower(branch) == "master" {
-		refName = plumbing.Master
-	} else {
-		refName = plumbing.NewBranchReferenceName(branch)
+		return plumbing.Master
 	}
+	return plumbing.NewBranchReferenceName(branch)
+}
+
+func cloneRepo(url string, refName plumbing.ReferenceName, depth int) (*git.Repository, error) {
 	r, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
 		URL:           url,
 		ReferenceName: refName,
+		SingleBranch:  true,
+		Depth:         depth,
 	})
 	return r, err
 }
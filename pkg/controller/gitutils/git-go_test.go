@@ -0,0 +1,98 @@
+package gitutils
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+func newKey(t *testing.T, name string) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return entity, buf.String()
+}
+
+func TestVerifyCommit(t *testing.T) {
+	trusted, trustedKey := newKey(t, "trusted")
+	untrusted, untrustedKey := newKey(t, "untrusted")
+
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(signKey *openpgp.Entity) *object.Commit {
+		hash, err := w.Commit("Change", &git.CommitOptions{
+			Author:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			SignKey: signKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := r.CommitObject(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	signed := commit(trusted)
+	signedByOther := commit(untrusted)
+	unsigned := commit(nil)
+	tagged := commit(nil)
+	_, err = r.CreateTag("v1", tagged.Hash, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "Release",
+		SignKey: trusted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		commit  *object.Commit
+		keys    []string
+		wantErr bool
+	}{
+		{name: "no keys accept unsigned commits", commit: unsigned},
+		{name: "signed by a trusted key", commit: signed, keys: []string{trustedKey}},
+		{name: "signed by one of the trusted keys", commit: signed, keys: []string{untrustedKey, trustedKey}},
+		{name: "signed by an untrusted key", commit: signedByOther, keys: []string{trustedKey}, wantErr: true},
+		{name: "unsigned", commit: unsigned, keys: []string{trustedKey}, wantErr: true},
+		{name: "signed tag", commit: tagged, keys: []string{trustedKey}},
+		{name: "signed tag by an untrusted key", commit: tagged, keys: []string{untrustedKey}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := verifyCommit(r, tt.commit, tt.keys)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: verifyCommit() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if verr, ok := err.(*VerificationError); tt.wantErr && (!ok || verr.Hash != tt.commit.Hash) {
+			t.Errorf("%s: verifyCommit() error = %#v, want a VerificationError of the commit", tt.name, err)
+		}
+	}
+}
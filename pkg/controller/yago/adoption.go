@@ -0,0 +1,63 @@
+package yago
+
+import (
+	"context"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// controllingYago returns the controller reference of an object if it points to a Yago other than instance
+func controllingYago(instance *yagov1alpha1.Yago, obj metav1.Object) *metav1.OwnerReference {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.UID == instance.GetUID() || owner.Kind != "Yago" {
+		return nil
+	}
+	if gv, err := schema.ParseGroupVersion(owner.APIVersion); err != nil || gv.Group != yagov1alpha1.SchemeGroupVersion.Group {
+		return nil
+	}
+	return owner
+}
+
+// adopt takes over an existing object not controlled by instance, as far as the adoption policy
+// allows. Objects managed by another Yago never get here, applyManifest skips them as conflicts.
+// Objects the instance cannot own are taken over by their tracking label alone.
+func (r *ReconcileYago) adopt(instance *yagov1alpha1.Yago, found *unstructured.Unstructured) error {
+	if managedBy(instance, found) {
+		// Objects created before tracking labels existed get them on the next sync
+		if found.GetLabels()[yagov1alpha1.InstanceLabel] == string(instance.GetUID()) {
+			return nil
+		}
+		patch := client.MergeFrom(found.DeepCopy())
+		setTracking(instance, found)
+		return r.objects.Patch(context.TODO(), found, patch)
+	}
+	policy := instance.Spec.Adopt
+	if policy == "" || policy == yagov1alpha1.AdoptNever {
+		return nil
+	}
+	controller := metav1.GetControllerOf(found)
+	if controller != nil && policy == yagov1alpha1.AdoptIfUnowned {
+		return nil
+	}
+	patch := client.MergeFrom(found.DeepCopy())
+	if controller != nil {
+		// The previous controller stays an owner, but no longer a controller
+		owners := found.GetOwnerReferences()
+		for i := range owners {
+			owners[i].Controller = nil
+		}
+		found.SetOwnerReferences(owners)
+	}
+	if ownable(instance, found.GetNamespace()) {
+		if err := controllerutil.SetControllerReference(instance, found, r.scheme); err != nil {
+			return err
+		}
+	}
+	setTracking(instance, found)
+	return r.objects.Patch(context.TODO(), found, patch)
+}
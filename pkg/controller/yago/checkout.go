@@ -0,0 +1,100 @@
+package yago
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// checkout is the commit a Yago fetched to apply, along with its tree
+type checkout struct {
+	// source is the repository and branch the commit was fetched from
+	source string
+	// request is the requestedAt annotation the commit was fetched for
+	request string
+	// keys is the digest of the trusted keys the commit was verified with
+	keys   string
+	ref    *plumbing.Reference
+	commit *object.Commit
+	tree   *object.Tree
+}
+
+// checkoutCache keeps the checkout of every Yago between reconciles, so that the repository is
+// only fetched again when the Yago changes its repository, branch or trusted keys or a sync is requested
+type checkoutCache struct {
+	lock      sync.Mutex
+	checkouts map[types.UID]*checkout
+}
+
+func newCheckoutCache() *checkoutCache {
+	return &checkoutCache{checkouts: map[types.UID]*checkout{}}
+}
+
+// checkoutSource identifies the repository and branch of instance
+func checkoutSource(instance *yagov1alpha1.Yago) string {
+	return instance.Spec.Repository + "#" + instance.Spec.BranchReference
+}
+
+// checkoutRequest is the sync request pending on instance, if any
+func checkoutRequest(instance *yagov1alpha1.Yago) string {
+	return instance.GetAnnotations()[yagov1alpha1.RequestedAtAnnotation]
+}
+
+// keysDigest identifies a set of trusted keys. The signature of a commit does not change, so a
+// commit verified with the same keys before is still verified.
+func keysDigest(trustedKeys []string) string {
+	h := sha256.New()
+	for _, key := range trustedKeys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the checkout of instance, nil if it has to be fetched. A changed requestedAt
+// annotation asks for a fresh fetch, which is kept until the annotation changes again. Changed
+// trusted keys ask for a fresh fetch too, which verifies the commit with them.
+func (c *checkoutCache) get(instance *yagov1alpha1.Yago, trustedKeys []string) *checkout {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	co, ok := c.checkouts[instance.UID]
+	if !ok || co.source != checkoutSource(instance) || co.request != checkoutRequest(instance) ||
+		co.keys != keysDigest(trustedKeys) {
+		return nil
+	}
+	return co
+}
+
+// set keeps the commit fetched for instance and verified with trustedKeys, replacing the one it had
+func (c *checkoutCache) set(
+	instance *yagov1alpha1.Yago,
+	trustedKeys []string,
+	ref *plumbing.Reference,
+	commit *object.Commit,
+	tree *object.Tree) *checkout {
+
+	co := &checkout{
+		source:  checkoutSource(instance),
+		request: checkoutRequest(instance),
+		keys:    keysDigest(trustedKeys),
+		ref:     ref,
+		commit:  commit,
+		tree:    tree,
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checkouts[instance.UID] = co
+	return co
+}
+
+// forget drops the checkout of instance, so that the next reconcile fetches again
+func (c *checkoutCache) forget(instance *yagov1alpha1.Yago) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.checkouts, instance.UID)
+}
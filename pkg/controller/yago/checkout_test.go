@@ -0,0 +1,59 @@
+package yago
+
+import (
+	"testing"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCheckoutCache(t *testing.T) {
+	yago := func(uid, repository, branch string) *yagov1alpha1.Yago {
+		instance := &yagov1alpha1.Yago{}
+		instance.UID = types.UID(uid)
+		instance.Spec.Repository, instance.Spec.BranchReference = repository, branch
+		return instance
+	}
+	c := newCheckoutCache()
+	keys := []string{"trusted key"}
+	signed := yago("a", "https://example.com/repo.git", "master")
+	head := plumbing.NewHashReference(plumbing.Master, plumbing.NewHash("0123456789012345678901234567890123456789"))
+	if c.get(signed, keys) != nil {
+		t.Fatalf("get() of a Yago that fetched nothing is not nil")
+	}
+	co := c.set(signed, keys, head, nil, nil)
+	if c.get(signed, keys) != co {
+		t.Errorf("get() does not return the checkout of the Yago")
+	}
+	// Another Yago of the same repository has to fetch, and verify, on its own
+	if c.get(yago("b", "https://example.com/repo.git", "master"), keys) != nil {
+		t.Errorf("get() returns the checkout of another Yago")
+	}
+	if c.get(yago("a", "https://example.com/repo.git", "develop"), keys) != nil {
+		t.Errorf("get() returns the checkout of another branch")
+	}
+	if c.get(yago("a", "https://example.com/other.git", "master"), keys) != nil {
+		t.Errorf("get() returns the checkout of another repository")
+	}
+	// A sync request fetches again once, the fetched commit is kept while the request is handled
+	signed.SetAnnotations(map[string]string{yagov1alpha1.RequestedAtAnnotation: "2020-01-01T00:00:00Z"})
+	if c.get(signed, keys) != nil {
+		t.Errorf("get() returns the checkout fetched before the sync request")
+	}
+	co = c.set(signed, keys, head, nil, nil)
+	if c.get(signed, keys) != co {
+		t.Errorf("get() does not return the checkout fetched for the sync request")
+	}
+	// A commit is verified again, which means fetching it again, once the trusted keys change
+	if c.get(signed, []string{"trusted key", "new key"}) != nil {
+		t.Errorf("get() returns the checkout verified with other keys")
+	}
+	if c.get(signed, nil) != nil {
+		t.Errorf("get() returns the checkout verified with keys that are gone")
+	}
+	c.forget(signed)
+	if c.get(signed, keys) != nil {
+		t.Errorf("get() returns a forgotten checkout")
+	}
+}
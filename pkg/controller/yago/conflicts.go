@@ -0,0 +1,101 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// instanceName is the namespace/name of a Yago as recorded on the objects it manages
+func instanceName(instance *yagov1alpha1.Yago) string {
+	return instance.Namespace + "/" + instance.Name
+}
+
+// setTracking marks obj as managed by instance
+func setTracking(instance *yagov1alpha1.Yago, obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[yagov1alpha1.InstanceLabel] = string(instance.GetUID())
+	obj.SetLabels(labels)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[yagov1alpha1.InstanceAnnotation] = instanceName(instance)
+	obj.SetAnnotations(annotations)
+}
+
+// clearTracking removes the marks of instance from obj
+func clearTracking(instance *yagov1alpha1.Yago, obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels[yagov1alpha1.InstanceLabel] != string(instance.GetUID()) {
+		return
+	}
+	delete(labels, yagov1alpha1.InstanceLabel)
+	obj.SetLabels(labels)
+	annotations := obj.GetAnnotations()
+	delete(annotations, yagov1alpha1.InstanceAnnotation)
+	obj.SetAnnotations(annotations)
+}
+
+// managedBy tells whether obj is managed by instance: through its controller reference, or its
+// tracking label for objects the instance cannot own
+func managedBy(instance *yagov1alpha1.Yago, obj metav1.Object) bool {
+	if !ownable(instance, obj.GetNamespace()) {
+		return obj.GetLabels()[yagov1alpha1.InstanceLabel] == string(instance.GetUID())
+	}
+	return metav1.IsControlledBy(obj, instance)
+}
+
+// managingYago returns the namespace/name of another Yago managing obj, either through its
+// controller reference or its tracking label. It is empty if no other Yago manages obj.
+func (r *ReconcileYago) managingYago(instance *yagov1alpha1.Yago, obj metav1.Object) (string, error) {
+	if owner := controllingYago(instance, obj); owner != nil {
+		return obj.GetNamespace() + "/" + owner.Name, nil
+	}
+	uid := obj.GetLabels()[yagov1alpha1.InstanceLabel]
+	if uid == "" || uid == string(instance.GetUID()) {
+		return "", nil
+	}
+	// Labels outlive their Yago, only a Yago that still exists is a conflict
+	name := obj.GetAnnotations()[yagov1alpha1.InstanceAnnotation]
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		return "", nil
+	}
+	other := &yagov1alpha1.Yago{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: parts[0], Name: parts[1]}, other)
+	if errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if string(other.GetUID()) != uid {
+		return "", nil
+	}
+	return name, nil
+}
+
+// setConflictCondition reports the manifests skipped because another Yago manages their objects
+func setConflictCondition(instance *yagov1alpha1.Yago, manifests []*manifest) {
+	var conflicts []string
+	for _, m := range manifests {
+		if m.conflict != "" {
+			conflicts = append(conflicts, fmt.Sprintf("%s %s is managed by Yago %s", m.gvk.Kind, m.obj.GetName(), m.conflict))
+		}
+	}
+	if len(conflicts) == 0 {
+		instance.Status.SetCondition(yagov1alpha1.ConditionConflict, corev1.ConditionFalse, "NoConflict", "")
+		return
+	}
+	instance.Status.SetCondition(yagov1alpha1.ConditionConflict, corev1.ConditionTrue, "ManagedByAnotherYago",
+		fmt.Sprintf("Skipped by Yago %s: %s", instanceName(instance), strings.Join(conflicts, "; ")))
+}
@@ -0,0 +1,342 @@
+package yago
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fieldPath is a path to a field of an object
+type fieldPath []pathStep
+
+// pathStep is a map key, a list index, or a list element selected by the value of one of its
+// fields. A selected element is found wherever it is in the list, and index is where it was
+// found, which is where it is inserted into lists that lack it.
+type pathStep struct {
+	key   string
+	field string
+	value string
+	index int
+}
+
+// pathOf is the path made of the map keys and list indexes in keys
+func pathOf(keys ...string) fieldPath {
+	path := make(fieldPath, 0, len(keys))
+	for _, key := range keys {
+		path = append(path, pathStep{key: key})
+	}
+	return path
+}
+
+// String formats the path as a JSON pointer, with selected elements at the index they were found
+func (path fieldPath) String() string {
+	var b strings.Builder
+	for _, step := range path {
+		b.WriteString("/")
+		if step.field != "" {
+			b.WriteString(strconv.Itoa(step.index))
+		} else {
+			b.WriteString(step.key)
+		}
+	}
+	return b.String()
+}
+
+// find returns the index of the list element the step leads to
+func (step pathStep) find(list []interface{}) (int, bool) {
+	if step.field == "" {
+		i, err := strconv.Atoi(step.key)
+		return i, err == nil && i >= 0 && i < len(list)
+	}
+	for i, child := range list {
+		element, ok := child.(map[string]interface{})
+		if ok && fmt.Sprint(element[step.field]) == step.value {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// ignoredPaths resolves the ignore rules of the instance and the annotation of a manifest
+// against the desired and live objects
+func ignoredPaths(instance *yagov1alpha1.Yago, m *manifest, live *unstructured.Unstructured) ([]fieldPath, error) {
+	var pointers, jsonPaths []string
+	for _, rule := range instance.Spec.IgnoreDifferences {
+		if rule.Group != m.gvk.Group || rule.Kind != m.gvk.Kind || (rule.Name != "" && rule.Name != m.obj.GetName()) {
+			continue
+		}
+		pointers = append(pointers, rule.JSONPointers...)
+		jsonPaths = append(jsonPaths, rule.JSONPaths...)
+	}
+	if annotation, ok := m.obj.GetAnnotations()[yagov1alpha1.IgnoreDifferencesAnnotation]; ok {
+		for _, p := range strings.Split(annotation, ",") {
+			pointers = append(pointers, strings.TrimSpace(p))
+		}
+	}
+
+	var paths []fieldPath
+	for _, p := range pointers {
+		path, err := parseJSONPointer(p)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	for _, p := range jsonPaths {
+		segments, err := parseJSONPath(p)
+		if err != nil {
+			return nil, err
+		}
+		// Wildcards and filters may match different elements in each object
+		paths = append(paths, expandJSONPath(m.obj.Object, segments)...)
+		paths = append(paths, expandJSONPath(live.Object, segments)...)
+	}
+	return paths, nil
+}
+
+// ignoreDifferences copies the ignored fields of live into desired, so that they are neither
+// compared nor patched
+func ignoreDifferences(desired, live *unstructured.Unstructured, paths []fieldPath) {
+	for _, path := range paths {
+		if value, ok := getField(live.Object, path); ok {
+			setField(desired.Object, path, runtime.DeepCopyJSONValue(value))
+		} else {
+			removeField(desired.Object, path)
+		}
+	}
+}
+
+// parseJSONPointer parses an RFC 6901 JSON pointer
+func parseJSONPointer(pointer string) (fieldPath, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	var path fieldPath
+	for _, token := range strings.Split(pointer[1:], "/") {
+		path = append(path, pathStep{key: strings.NewReplacer("~1", "/", "~0", "~").Replace(token)})
+	}
+	return path, nil
+}
+
+// jsonPathSegment is a step of a JSONPath expression: a field name, a list index, a wildcard
+// or a filter on a field of list elements
+type jsonPathSegment struct {
+	name        string
+	index       int
+	wildcard    bool
+	filterField string
+	filterValue string
+	isIndex     bool
+	isFilter    bool
+}
+
+// parseJSONPath parses the subset of JSONPath that locates fields: .field, ['field'], [n], [*]
+// and [?(@.field=='value')], optionally wrapped in braces and prefixed with $
+func parseJSONPath(expr string) ([]jsonPathSegment, error) {
+	p := strings.TrimSpace(expr)
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "{"), "}")
+	p = strings.TrimPrefix(p, "$")
+	var segments []jsonPathSegment
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q", expr)
+			}
+			if p[:end] == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			} else {
+				segments = append(segments, jsonPathSegment{name: p[:end]})
+			}
+			p = p[end:]
+		case '[':
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q", expr)
+			}
+			segment, err := parseJSONPathBracket(p[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: %v", expr, err)
+			}
+			segments = append(segments, segment)
+			p = p[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q", expr)
+		}
+	}
+	return segments, nil
+}
+
+func parseJSONPathBracket(inner string) (jsonPathSegment, error) {
+	switch {
+	case inner == "*":
+		return jsonPathSegment{wildcard: true}, nil
+	case strings.HasPrefix(inner, "?(@.") && strings.HasSuffix(inner, ")"):
+		condition := strings.SplitN(inner[4:len(inner)-1], "==", 2)
+		if len(condition) != 2 {
+			return jsonPathSegment{}, fmt.Errorf("unsupported filter %q", inner)
+		}
+		return jsonPathSegment{
+			isFilter:    true,
+			filterField: strings.TrimSpace(condition[0]),
+			filterValue: strings.Trim(strings.TrimSpace(condition[1]), `'"`),
+		}, nil
+	case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+		return jsonPathSegment{name: strings.Trim(inner, `'"`)}, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return jsonPathSegment{}, fmt.Errorf("unsupported subscript %q", inner)
+	}
+	return jsonPathSegment{index: index, isIndex: true}, nil
+}
+
+// expandJSONPath returns the paths of the fields of obj that the segments match. List elements
+// matched by a filter are selected by the filtered field, so that the paths also lead to them in
+// objects where they are elsewhere in the list or missing.
+func expandJSONPath(obj interface{}, segments []jsonPathSegment) []fieldPath {
+	if len(segments) == 0 {
+		return []fieldPath{{}}
+	}
+	var paths []fieldPath
+	prepend := func(step pathStep, child interface{}) {
+		for _, rest := range expandJSONPath(child, segments[1:]) {
+			paths = append(paths, append(fieldPath{step}, rest...))
+		}
+	}
+	segment := segments[0]
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		if segment.wildcard {
+			for key, child := range value {
+				prepend(pathStep{key: key}, child)
+			}
+		} else if !segment.isIndex && !segment.isFilter {
+			if child, ok := value[segment.name]; ok {
+				prepend(pathStep{key: segment.name}, child)
+			} else if len(segments) == 1 {
+				// A missing leaf still has to be removed from the other object
+				paths = append(paths, pathOf(segment.name))
+			}
+		}
+	case []interface{}:
+		for i, child := range value {
+			switch {
+			case segment.wildcard:
+			case segment.isIndex && segment.index == i:
+			case segment.isFilter:
+				element, ok := child.(map[string]interface{})
+				if !ok || fmt.Sprint(element[segment.filterField]) != segment.filterValue {
+					continue
+				}
+				prepend(pathStep{field: segment.filterField, value: segment.filterValue, index: i}, child)
+				continue
+			default:
+				continue
+			}
+			prepend(pathStep{key: strconv.Itoa(i)}, child)
+		}
+	}
+	return paths
+}
+
+func getField(obj interface{}, path fieldPath) (interface{}, bool) {
+	for _, step := range path {
+		switch value := obj.(type) {
+		case map[string]interface{}:
+			child, ok := value[step.key]
+			if !ok || step.field != "" {
+				return nil, false
+			}
+			obj = child
+		case []interface{}:
+			i, ok := step.find(value)
+			if !ok {
+				return nil, false
+			}
+			obj = value[i]
+		default:
+			return nil, false
+		}
+	}
+	return obj, true
+}
+
+// setField sets the field at path and returns obj, which is a new list if an element was
+// inserted into it. Missing maps along the way are created and missing selected elements are
+// inserted, but fields below missing list elements are not set.
+func setField(obj interface{}, path fieldPath, v interface{}) interface{} {
+	if len(path) == 0 {
+		return v
+	}
+	step := path[0]
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		if step.field != "" {
+			return obj
+		}
+		child, ok := value[step.key]
+		if !ok && len(path) > 1 {
+			child = map[string]interface{}{}
+		}
+		value[step.key] = setField(child, path[1:], v)
+	case []interface{}:
+		if i, ok := step.find(value); ok {
+			value[i] = setField(value[i], path[1:], v)
+		} else if step.field != "" && len(path) == 1 {
+			i := step.index
+			if i > len(value) {
+				i = len(value)
+			}
+			inserted := make([]interface{}, 0, len(value)+1)
+			inserted = append(inserted, value[:i]...)
+			inserted = append(inserted, v)
+			return append(inserted, value[i:]...)
+		}
+	}
+	return obj
+}
+
+// removeField removes the field at path and returns obj, which is a new list if an element was
+// removed from it. Only selected list elements are removed, as removing elements by index would
+// shift the indexes of the remaining ones.
+func removeField(obj interface{}, path fieldPath) interface{} {
+	if len(path) == 0 {
+		return obj
+	}
+	step := path[0]
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		child, ok := value[step.key]
+		if !ok || step.field != "" {
+			return obj
+		}
+		if len(path) == 1 {
+			delete(value, step.key)
+		} else {
+			value[step.key] = removeField(child, path[1:])
+		}
+	case []interface{}:
+		i, ok := step.find(value)
+		if !ok {
+			return obj
+		}
+		if len(path) > 1 {
+			value[i] = removeField(value[i], path[1:])
+		} else if step.field != "" {
+			removed := make([]interface{}, 0, len(value)-1)
+			removed = append(removed, value[:i]...)
+			return append(removed, value[i+1:]...)
+		}
+	}
+	return obj
+}
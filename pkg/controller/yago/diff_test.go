@@ -0,0 +1,192 @@
+package yago
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    []jsonPathSegment
+		wantErr bool
+	}{
+		{expr: ".spec.replicas", want: []jsonPathSegment{{name: "spec"}, {name: "replicas"}}},
+		{expr: "{$.spec.replicas}", want: []jsonPathSegment{{name: "spec"}, {name: "replicas"}}},
+		{expr: ".metadata.annotations['sidecar.istio.io/status']",
+			want: []jsonPathSegment{{name: "metadata"}, {name: "annotations"}, {name: "sidecar.istio.io/status"}}},
+		{expr: ".spec.ports[1].nodePort",
+			want: []jsonPathSegment{{name: "spec"}, {name: "ports"}, {index: 1, isIndex: true}, {name: "nodePort"}}},
+		{expr: ".spec.containers[*].image",
+			want: []jsonPathSegment{{name: "spec"}, {name: "containers"}, {wildcard: true}, {name: "image"}}},
+		{expr: ".metadata.labels.*", want: []jsonPathSegment{{name: "metadata"}, {name: "labels"}, {wildcard: true}}},
+		{expr: ".spec.containers[?(@.name=='app')]",
+			want: []jsonPathSegment{{name: "spec"}, {name: "containers"}, {isFilter: true, filterField: "name", filterValue: "app"}}},
+		{expr: "spec", wantErr: true},
+		{expr: ".spec..replicas", wantErr: true},
+		{expr: ".spec.containers[0", wantErr: true},
+		{expr: ".spec.containers[a]", wantErr: true},
+		{expr: ".spec.containers[?(@.name)]", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJSONPath(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseJSONPath(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func podSpec(containers ...string) map[string]interface{} {
+	var list []interface{}
+	for _, name := range containers {
+		list = append(list, map[string]interface{}{"name": name, "image": name + ":1"})
+	}
+	return map[string]interface{}{"spec": map[string]interface{}{"containers": list}}
+}
+
+func TestExpandJSONPath(t *testing.T) {
+	obj := podSpec("app", "istio-proxy")
+	obj["metadata"] = map[string]interface{}{"labels": map[string]interface{}{"a": "1", "b": "2"}}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{expr: ".spec.containers[*].image", want: []string{"/spec/containers/0/image", "/spec/containers/1/image"}},
+		{expr: ".spec.containers[1].name", want: []string{"/spec/containers/1/name"}},
+		{expr: ".spec.containers[2].name", want: nil},
+		{expr: ".spec.containers[?(@.name=='istio-proxy')].image", want: []string{"/spec/containers/1/image"}},
+		{expr: ".spec.containers[?(@.name=='other')]", want: nil},
+		{expr: ".metadata.labels.*", want: []string{"/metadata/labels/a", "/metadata/labels/b"}},
+		// Missing leaves are still listed, missing parents are not
+		{expr: ".spec.replicas", want: []string{"/spec/replicas"}},
+		{expr: ".status.replicas", want: nil},
+	}
+	for _, tt := range tests {
+		segments, err := parseJSONPath(tt.expr)
+		if err != nil {
+			t.Fatalf("parseJSONPath(%q) error = %v", tt.expr, err)
+		}
+		var got []string
+		for _, path := range expandJSONPath(obj, segments) {
+			got = append(got, path.String())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandJSONPath(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreDifferences(t *testing.T) {
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	tests := []struct {
+		name        string
+		rule        yagov1alpha1.ResourceIgnoreDifferences
+		annotation  string
+		desired     map[string]interface{}
+		live        map[string]interface{}
+		wantDesired map[string]interface{}
+	}{
+		{
+			name:        "pointer keeps the live value",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+			desired:     map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			live:        map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(5)}},
+			wantDesired: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(5)}},
+		},
+		{
+			name:        "pointer removes a field missing live",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+			desired:     map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			live:        map[string]interface{}{"spec": map[string]interface{}{}},
+			wantDesired: map[string]interface{}{"spec": map[string]interface{}{}},
+		},
+		{
+			name:        "annotation lists pointers",
+			annotation:  "/spec/replicas, /spec/paused",
+			desired:     map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			live:        map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(5), "paused": true}},
+			wantDesired: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(5), "paused": true}},
+		},
+		{
+			name:        "rule of another kind does not apply",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "", Kind: "Service", JSONPointers: []string{"/spec/replicas"}},
+			desired:     map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			live:        map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(5)}},
+			wantDesired: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+		},
+		{
+			name:        "rule of another name does not apply",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", Name: "other", JSONPointers: []string{"/spec/replicas"}},
+			desired:     map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			live:        map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(5)}},
+			wantDesired: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+		},
+		{
+			name:        "wildcard keeps every image",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", JSONPaths: []string{".spec.containers[*].image"}},
+			desired:     podSpec("app"),
+			live:        map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "app", "image": "app:2"}}}},
+			wantDesired: map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "app", "image": "app:2"}}}},
+		},
+		{
+			name:        "injected sidecar is kept in place",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", JSONPaths: []string{".spec.containers[?(@.name=='istio-proxy')]"}},
+			desired:     podSpec("app", "worker"),
+			live:        podSpec("app", "istio-proxy", "worker"),
+			wantDesired: podSpec("app", "istio-proxy", "worker"),
+		},
+		{
+			name:        "injected sidecar is appended to a shorter list",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", JSONPaths: []string{".spec.containers[?(@.name=='istio-proxy')]"}},
+			desired:     podSpec(),
+			live:        podSpec("app", "istio-proxy"),
+			wantDesired: podSpec("istio-proxy"),
+		},
+		{
+			name:        "element missing live is removed",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", JSONPaths: []string{".spec.containers[?(@.name=='istio-proxy')]"}},
+			desired:     podSpec("istio-proxy", "app"),
+			live:        podSpec("app"),
+			wantDesired: podSpec("app"),
+		},
+		{
+			name:        "field of a filtered element",
+			rule:        yagov1alpha1.ResourceIgnoreDifferences{Group: "apps", Kind: "Deployment", JSONPaths: []string{".spec.containers[?(@.name=='app')].image"}},
+			desired:     podSpec("app", "worker"),
+			live:        map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "worker", "image": "worker:1"}, map[string]interface{}{"name": "app", "image": "app:2"}}}},
+			wantDesired: map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "app", "image": "app:2"}, map[string]interface{}{"name": "worker", "image": "worker:1"}}}},
+		},
+	}
+	for _, tt := range tests {
+		instance := &yagov1alpha1.Yago{}
+		if tt.rule.Kind != "" {
+			instance.Spec.IgnoreDifferences = []yagov1alpha1.ResourceIgnoreDifferences{tt.rule}
+		}
+		desired := &unstructured.Unstructured{Object: tt.desired}
+		desired.SetName("frontend")
+		if tt.annotation != "" {
+			desired.SetAnnotations(map[string]string{yagov1alpha1.IgnoreDifferencesAnnotation: tt.annotation})
+		}
+		live := &unstructured.Unstructured{Object: tt.live}
+		paths, err := ignoredPaths(instance, &manifest{obj: desired, gvk: deployment}, live)
+		if err != nil {
+			t.Errorf("%s: ignoredPaths() error = %v", tt.name, err)
+			continue
+		}
+		got := desired.DeepCopy()
+		ignoreDifferences(got, live, paths)
+		if !reflect.DeepEqual(got.Object["spec"], tt.wantDesired["spec"]) {
+			t.Errorf("%s: ignoreDifferences() = %v, want %v", tt.name, got.Object["spec"], tt.wantDesired["spec"])
+		}
+	}
+}
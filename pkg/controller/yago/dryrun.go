@@ -0,0 +1,82 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var namespaceGroupKind = schema.GroupKind{Kind: "Namespace"}
+
+// dryRunClient sends every write as a server-side dry run
+type dryRunClient struct {
+	client.Client
+}
+
+func (c dryRunClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	return c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c dryRunClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	return c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c dryRunClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
+
+func (c dryRunClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// dryRunObjects applies every object as a server-side dry run and fails with the complete list of
+// objects that would not apply. Objects whose kind or namespace are only created by the commit
+// itself cannot be checked this way and are skipped.
+func (r *ReconcileYago) dryRunObjects(instance *yagov1alpha1.Yago, objects []*manifest, reqLogger logr.Logger) error {
+	dry := *r
+	dry.objects = dryRunClient{r.objects}
+	dry.dryRun = true
+	dryLogger := reqLogger.WithValues("DryRun", true)
+
+	pendingNamespaces := map[string]bool{}
+	var failures []string
+	for _, m := range objects {
+		if err := r.resolveNamespace(instance, m); meta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", m.path, err))
+			continue
+		}
+		if m.namespace != "" && pendingNamespaces[m.namespace] {
+			continue
+		}
+		if m.gvk.GroupKind() == namespaceGroupKind {
+			live := &unstructured.Unstructured{}
+			live.SetGroupVersionKind(m.gvk)
+			err := r.objects.Get(context.TODO(), types.NamespacedName{Name: m.obj.GetName()}, live)
+			if errors.IsNotFound(err) {
+				pendingNamespaces[m.obj.GetName()] = true
+			}
+		}
+		// The manifest is applied again for real, the dry run must not leave server fields on it
+		dryManifest := *m
+		dryManifest.obj = m.obj.DeepCopy()
+		if _, err := dry.applyManifest(instance, &dryManifest, &syncStats{}, dryLogger); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", m.path, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("dry run failed, nothing was applied: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package yago
+
+import (
+	"context"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func hasFinalizer(instance *yagov1alpha1.Yago) bool {
+	for _, f := range instance.GetFinalizers() {
+		if f == yagov1alpha1.Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureFinalizer adds the finalizer to the instance if it is missing
+func (r *ReconcileYago) ensureFinalizer(instance *yagov1alpha1.Yago) error {
+	if hasFinalizer(instance) {
+		return nil
+	}
+	instance.SetFinalizers(append(instance.GetFinalizers(), yagov1alpha1.Finalizer))
+	return r.client.Update(context.TODO(), instance)
+}
+
+// finalize carries out the deletion policy of a deleted instance and removes its finalizer.
+// Deleted objects are garbage collected through their owner references, except for objects the
+// instance cannot own, which are deleted here. Orphaned objects have the owner reference to the
+// instance removed.
+func (r *ReconcileYago) finalize(instance *yagov1alpha1.Yago, reqLogger logr.Logger) error {
+	if !hasFinalizer(instance) {
+		return nil
+	}
+	if instance.Spec.DeletionPolicy == yagov1alpha1.DeletionPolicyOrphan {
+		reqLogger.Info("Orphaning managed objects")
+		if err := r.orphanObjects(instance, reqLogger); err != nil {
+			return err
+		}
+	} else if err := r.deleteUnowned(instance, reqLogger); err != nil {
+		return err
+	}
+	return r.removeFinalizer(instance)
+}
+
+// removeFinalizer lets a deleted instance go, along with the clients of its remote cluster and
+// its checkout
+func (r *ReconcileYago) removeFinalizer(instance *yagov1alpha1.Yago) error {
+	if !hasFinalizer(instance) {
+		return nil
+	}
+	var finalizers []string
+	for _, f := range instance.GetFinalizers() {
+		if f != yagov1alpha1.Finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	instance.SetFinalizers(finalizers)
+	if err := r.client.Update(context.TODO(), instance); err != nil {
+		return err
+	}
+	r.clients.evict(types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}.String())
+	r.checkouts.forget(instance)
+	return nil
+}
+
+// unreachable tells whether an object can no longer be read or changed while finalizing, because
+// it is gone or because the ServiceAccount of the instance lost its permissions, such as when the
+// namespace holding its RoleBinding is being deleted. Such objects are left as they are, so that
+// the finalizer can still be removed.
+func unreachable(err error) bool {
+	return errors.IsNotFound(err) || errors.IsForbidden(err)
+}
+
+// orphanObjects releases every object managed by the instance
+func (r *ReconcileYago) orphanObjects(instance *yagov1alpha1.Yago, reqLogger logr.Logger) error {
+	objects, err := r.managedObjects(instance, reqLogger)
+	if err != nil {
+		return err
+	}
+	for _, live := range objects {
+		if err := r.releaseObject(instance, live); unreachable(err) {
+			reqLogger.Info("Cannot release object", "Kind", live.GetKind(), "Namespace", live.GetNamespace(), "Name", live.GetName(), "Reason", err.Error())
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUnowned deletes the objects managed by the instance that are cluster-scoped or in another
+// namespace, which the garbage collector does not delete along with it
+func (r *ReconcileYago) deleteUnowned(instance *yagov1alpha1.Yago, reqLogger logr.Logger) error {
+	objects, err := r.managedObjects(instance, reqLogger)
+	if err != nil {
+		return err
+	}
+	for _, live := range objects {
+		if ownable(instance, live.GetNamespace()) || !managedBy(instance, live) {
+			continue
+		}
+		reqLogger.Info("Deleting unowned object", "Kind", live.GetKind(), "Namespace", live.GetNamespace(), "Name", live.GetName())
+		if err := r.objects.Delete(context.TODO(), live); errors.IsForbidden(err) {
+			reqLogger.Info("Cannot delete object", "Kind", live.GetKind(), "Namespace", live.GetNamespace(), "Name", live.GetName(), "Reason", err.Error())
+		} else if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedObjects returns every live object managed by the instance. These are found by their
+// tracking label, in every namespace the instance applies to and among cluster-scoped objects if
+// those are allowed, so that objects created by a sync that failed before the inventory was written
+// are found too. Objects of the inventory are added, as they may predate tracking labels.
+func (r *ReconcileYago) managedObjects(instance *yagov1alpha1.Yago, reqLogger logr.Logger) ([]*unstructured.Unstructured, error) {
+	config := r.clients.config
+	if r.cluster != nil {
+		config = r.cluster.config
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	resourceLists, err := dc.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	resourceLists = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list", "delete"}}, resourceLists)
+
+	seen := map[types.UID]bool{}
+	var objects []*unstructured.Unstructured
+	add := func(obj *unstructured.Unstructured) {
+		if !seen[obj.GetUID()] {
+			seen[obj.GetUID()] = true
+			objects = append(objects, obj)
+		}
+	}
+	selector := client.MatchingLabels{yagov1alpha1.InstanceLabel: string(instance.GetUID())}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resourceList.APIResources {
+			namespaces := []string{""}
+			if resource.Namespaced {
+				namespaces = managedNamespaces(instance)
+			} else if !*allowClusterScoped {
+				continue
+			}
+			for _, namespace := range namespaces {
+				list := &unstructured.UnstructuredList{}
+				list.SetGroupVersionKind(gv.WithKind(resource.Kind + "List"))
+				err := r.objects.List(context.TODO(), list, client.InNamespace(namespace), selector)
+				if errors.IsForbidden(err) || errors.IsNotFound(err) || errors.IsMethodNotSupported(err) {
+					reqLogger.Info("Cannot look for managed objects", "Resource", resource.Name, "Namespace", namespace, "Reason", err.Error())
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				for i := range list.Items {
+					add(&list.Items[i])
+				}
+			}
+		}
+	}
+	for _, o := range instance.Status.Inventory {
+		live, err := r.getInventoryObject(instance, o)
+		if errors.IsForbidden(err) {
+			reqLogger.Info("Cannot read inventory object", "Kind", o.Kind, "Namespace", o.Namespace, "Name", o.Name, "Reason", err.Error())
+			continue
+		} else if err != nil {
+			return nil, err
+		} else if live != nil {
+			add(live)
+		}
+	}
+	return objects, nil
+}
+
+// getInventoryObject reads the live object of an inventory entry, nil if it no longer exists
+func (r *ReconcileYago) getInventoryObject(
+	instance *yagov1alpha1.Yago,
+	o yagov1alpha1.ManagedObject) (*unstructured.Unstructured, error) {
+
+	gv, err := schema.ParseGroupVersion(o.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	gvk := gv.WithKind(o.Kind)
+	namespace, err := r.inventoryNamespace(instance, o, gvk)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(gvk)
+	err = r.objects.Get(context.TODO(), types.NamespacedName{Name: o.Name, Namespace: namespace}, live)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+// releaseObject removes the owner reference and tracking marks of the instance from a live object
+func (r *ReconcileYago) releaseObject(instance *yagov1alpha1.Yago, live *unstructured.Unstructured) error {
+	var owners []metav1.OwnerReference
+	for _, owner := range live.GetOwnerReferences() {
+		if owner.UID != instance.GetUID() {
+			owners = append(owners, owner)
+		}
+	}
+	tracked := live.GetLabels()[yagov1alpha1.InstanceLabel] == string(instance.GetUID())
+	if len(owners) == len(live.GetOwnerReferences()) && !tracked {
+		return nil
+	}
+	patch := client.MergeFrom(live.DeepCopy())
+	live.SetOwnerReferences(owners)
+	clearTracking(instance, live)
+	return r.objects.Patch(context.TODO(), live, patch)
+}
@@ -0,0 +1,103 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// forbiddenClient refuses the requests of a ServiceAccount that lost its RoleBinding
+type forbiddenClient struct {
+	client.Client
+	get bool
+}
+
+func forbidden(name string) error {
+	return errors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, name,
+		fmt.Errorf("User \"system:serviceaccount:default:deployer\" cannot access resource"))
+}
+
+func (c forbiddenClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if c.get {
+		return forbidden(key.Name)
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c forbiddenClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return forbidden(obj.(metav1.Object).GetName())
+}
+
+func (c forbiddenClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return forbidden(obj.(metav1.Object).GetName())
+}
+
+func TestFinalizeForbidden(t *testing.T) {
+	// An API server without any resource to discover, so only the inventory is looked at
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	tests := []struct {
+		name   string
+		policy yagov1alpha1.DeletionPolicy
+		get    bool
+	}{
+		{name: "delete forbidden", policy: yagov1alpha1.DeletionPolicyDelete},
+		{name: "release forbidden", policy: yagov1alpha1.DeletionPolicyOrphan},
+		{name: "read forbidden", policy: yagov1alpha1.DeletionPolicyDelete, get: true},
+	}
+	for _, tt := range tests {
+		now := metav1.Now()
+		instance := &yagov1alpha1.Yago{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "example",
+				Namespace:         "default",
+				UID:               "1234",
+				Finalizers:        []string{yagov1alpha1.Finalizer},
+				DeletionTimestamp: &now,
+			},
+			Spec: yagov1alpha1.YagoSpec{
+				DeletionPolicy:     tt.policy,
+				ServiceAccountName: "deployer",
+				AllowedNamespaces:  []string{"apps"},
+			},
+			Status: yagov1alpha1.YagoStatus{
+				Inventory: []yagov1alpha1.ManagedObject{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "apps", Name: "settings"}},
+			},
+		}
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "apps"}}
+		setTracking(instance, configMap)
+		s := testScheme(t)
+		cl := fake.NewFakeClientWithScheme(s, instance, configMap)
+		r := &ReconcileYago{
+			client:    cl,
+			objects:   forbiddenClient{Client: cl, get: tt.get},
+			scheme:    s,
+			clients:   newClientCache(&rest.Config{Host: server.URL}, s, nil),
+			checkouts: newCheckoutCache(),
+		}
+		if err := r.finalize(instance, log); err != nil {
+			t.Errorf("%s: finalize() error = %v", tt.name, err)
+			continue
+		}
+		found := &yagov1alpha1.Yago{}
+		if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, found); err != nil {
+			t.Fatal(err)
+		}
+		if hasFinalizer(found) {
+			t.Errorf("%s: finalizer was kept, the Yago would stay Terminating", tt.name)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// healthCheckInterval is how often the health of objects is assessed again until they are all healthy
+var healthCheckInterval = time.Second * 30
+
+// assessHealth tells the health of a live object. Kinds without a specific check are healthy as
+// soon as they exist.
+func (r *ReconcileYago) assessHealth(obj *unstructured.Unstructured) (yagov1alpha1.HealthStatus, string, error) {
+	switch obj.GetKind() {
+	case "Deployment", "StatefulSet", "DaemonSet", "DeploymentConfig":
+		health, message := rolloutHealth(obj)
+		return health, message, nil
+	case "Job":
+		health, message := jobHealth(obj)
+		return health, message, nil
+	case "PersistentVolumeClaim":
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		switch corev1.PersistentVolumeClaimPhase(phase) {
+		case corev1.ClaimBound:
+			return yagov1alpha1.HealthHealthy, "", nil
+		case corev1.ClaimLost:
+			return yagov1alpha1.HealthDegraded, "claim lost its volume", nil
+		}
+		return yagov1alpha1.HealthProgressing, "waiting for the claim to be bound", nil
+	case "Service":
+		return r.serviceHealth(obj)
+	case "Route":
+		health, message := routeHealth(obj)
+		return health, message, nil
+	}
+	return yagov1alpha1.HealthHealthy, "", nil
+}
+
+// rolloutHealth checks the status of workloads against their spec
+func rolloutHealth(obj *unstructured.Unstructured) (yagov1alpha1.HealthStatus, string) {
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observed < obj.GetGeneration() {
+		return yagov1alpha1.HealthProgressing, "waiting for the rollout to be observed"
+	}
+	if condition := findCondition(obj, "Progressing"); condition != nil &&
+		condition["status"] == "False" && condition["reason"] == "ProgressDeadlineExceeded" {
+		return yagov1alpha1.HealthDegraded, fmt.Sprintf("rollout failed: %v", condition["message"])
+	}
+	var desired, updated, available int64
+	if obj.GetKind() == "DaemonSet" {
+		desired, _, _ = unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+		updated, _, _ = unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+		available, _, _ = unstructured.NestedInt64(obj.Object, "status", "numberAvailable")
+	} else {
+		desired = 1
+		if replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+			desired = replicas
+		}
+		updated, _, _ = unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+		if obj.GetKind() == "StatefulSet" {
+			available, _, _ = unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		} else {
+			available, _, _ = unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+		}
+	}
+	if updated < desired {
+		return yagov1alpha1.HealthProgressing, fmt.Sprintf("%d of %d replicas updated", updated, desired)
+	}
+	if available < desired {
+		return yagov1alpha1.HealthProgressing, fmt.Sprintf("%d of %d replicas available", available, desired)
+	}
+	return yagov1alpha1.HealthHealthy, ""
+}
+
+// jobHealth is healthy once the job succeeded
+func jobHealth(obj *unstructured.Unstructured) (yagov1alpha1.HealthStatus, string) {
+	if condition := findCondition(obj, "Failed"); condition != nil && condition["status"] == "True" {
+		return yagov1alpha1.HealthDegraded, fmt.Sprintf("job failed: %v", condition["message"])
+	}
+	if condition := findCondition(obj, "Complete"); condition != nil && condition["status"] == "True" {
+		return yagov1alpha1.HealthHealthy, ""
+	}
+	completions := int64(1)
+	if c, found, _ := unstructured.NestedInt64(obj.Object, "spec", "completions"); found {
+		completions = c
+	}
+	succeeded, _, _ := unstructured.NestedInt64(obj.Object, "status", "succeeded")
+	if succeeded >= completions {
+		return yagov1alpha1.HealthHealthy, ""
+	}
+	return yagov1alpha1.HealthProgressing, fmt.Sprintf("%d of %d completions", succeeded, completions)
+}
+
+// serviceHealth is healthy once a service with a selector has ready endpoints
+func (r *ReconcileYago) serviceHealth(obj *unstructured.Unstructured) (yagov1alpha1.HealthStatus, string, error) {
+	if selector, _, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); len(selector) == 0 {
+		return yagov1alpha1.HealthHealthy, "", nil
+	}
+	// Endpoints are read as unstructured, which bypasses the cache of the manager: it only holds
+	// objects of the watched namespace, not those of the target or allowed namespaces
+	endpoints := &unstructured.Unstructured{}
+	endpoints.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Endpoints"))
+	err := r.objects.Get(context.TODO(), types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, endpoints)
+	if errors.IsNotFound(err) {
+		return yagov1alpha1.HealthProgressing, "waiting for endpoints", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	subsets, _, _ := unstructured.NestedSlice(endpoints.Object, "subsets")
+	for _, s := range subsets {
+		subset, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if addresses, _, _ := unstructured.NestedSlice(subset, "addresses"); len(addresses) > 0 {
+			return yagov1alpha1.HealthHealthy, "", nil
+		}
+	}
+	return yagov1alpha1.HealthProgressing, "no ready endpoints", nil
+}
+
+// routeHealth is healthy once every router admitted the route
+func routeHealth(obj *unstructured.Unstructured) (yagov1alpha1.HealthStatus, string) {
+	ingresses, _, _ := unstructured.NestedSlice(obj.Object, "status", "ingress")
+	if len(ingresses) == 0 {
+		return yagov1alpha1.HealthProgressing, "waiting for the route to be admitted"
+	}
+	for _, i := range ingresses {
+		ingress, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(ingress, "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if ok && condition["type"] == "Admitted" && condition["status"] == "False" {
+				return yagov1alpha1.HealthDegraded, fmt.Sprintf("route rejected by %v: %v", ingress["routerName"], condition["message"])
+			}
+		}
+	}
+	return yagov1alpha1.HealthHealthy, ""
+}
+
+// findCondition returns the status condition of the given type of an object, nil if it is not set
+func findCondition(obj *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if condition, ok := c.(map[string]interface{}); ok && condition["type"] == conditionType {
+			return condition
+		}
+	}
+	return nil
+}
+
+// assessManifests reads the live object of each manifest and assesses its health
+func (r *ReconcileYago) assessManifests(manifests []*manifest) ([]yagov1alpha1.ManagedObject, error) {
+	inventory := make([]yagov1alpha1.ManagedObject, 0, len(manifests))
+	for _, m := range manifests {
+		// Objects skipped for a conflict are not managed by this Yago
+		if m.conflict != "" {
+			continue
+		}
+		entry := yagov1alpha1.ManagedObject{
+			APIVersion: m.gvk.GroupVersion().String(),
+			Kind:       m.gvk.Kind,
+			Namespace:  m.namespace,
+			Name:       m.obj.GetName(),
+		}
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(m.gvk)
+		err := r.objects.Get(context.TODO(), types.NamespacedName{Name: m.obj.GetName(), Namespace: m.namespace}, live)
+		if errors.IsNotFound(err) {
+			entry.Health = yagov1alpha1.HealthMissing
+		} else if err != nil {
+			return nil, err
+		} else if entry.Health, entry.Message, err = r.assessHealth(live); err != nil {
+			return nil, err
+		}
+		inventory = append(inventory, entry)
+	}
+	return inventory, nil
+}
+
+// overallHealth is the worst health of the inventory
+func overallHealth(inventory []yagov1alpha1.ManagedObject) (yagov1alpha1.HealthStatus, string) {
+	health, message := yagov1alpha1.HealthHealthy, ""
+	for _, o := range inventory {
+		switch o.Health {
+		case yagov1alpha1.HealthDegraded, yagov1alpha1.HealthMissing:
+			return yagov1alpha1.HealthDegraded, fmt.Sprintf("%s %s is %s", o.Kind, o.Name, o.Health)
+		case yagov1alpha1.HealthProgressing:
+			if health == yagov1alpha1.HealthHealthy {
+				health, message = yagov1alpha1.HealthProgressing, fmt.Sprintf("%s %s is %s", o.Kind, o.Name, o.Health)
+			}
+		}
+	}
+	return health, message
+}
+
+// waitForWave returns a waitingError until every object of the wave is healthy, and fails if any
+// of them is degraded
+func (r *ReconcileYago) waitForWave(wave *syncWave) error {
+	inventory, err := r.assessManifests(wave.manifests)
+	if err != nil {
+		return err
+	}
+	switch health, message := overallHealth(inventory); health {
+	case yagov1alpha1.HealthHealthy:
+		return nil
+	case yagov1alpha1.HealthDegraded:
+		return fmt.Errorf("sync wave %d is degraded: %s", wave.number, message)
+	default:
+		return waitingFor("waiting for sync wave %d: %s", wave.number, message)
+	}
+}
@@ -0,0 +1,229 @@
+package yago
+
+import (
+	"testing"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func liveObject(kind string, generation int64, spec, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec, "status": status}}
+	obj.SetKind(kind)
+	obj.SetGeneration(generation)
+	return obj
+}
+
+func conditionsOf(conditions ...map[string]interface{}) []interface{} {
+	list := make([]interface{}, 0, len(conditions))
+	for _, c := range conditions {
+		list = append(list, c)
+	}
+	return list
+}
+
+func TestRolloutHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		health yagov1alpha1.HealthStatus
+	}{
+		{
+			name: "rolled out deployment",
+			obj: liveObject("Deployment", 2, map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(3), "availableReplicas": int64(3)}),
+			health: yagov1alpha1.HealthHealthy,
+		},
+		{
+			name: "deployment defaults to one replica",
+			obj: liveObject("Deployment", 1, map[string]interface{}{},
+				map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(1), "availableReplicas": int64(1)}),
+			health: yagov1alpha1.HealthHealthy,
+		},
+		{
+			name: "generation not observed yet",
+			obj: liveObject("Deployment", 3, map[string]interface{}{"replicas": int64(1)},
+				map[string]interface{}{"observedGeneration": int64(2), "updatedReplicas": int64(1), "availableReplicas": int64(1)}),
+			health: yagov1alpha1.HealthProgressing,
+		},
+		{
+			name: "replicas being updated",
+			obj: liveObject("Deployment", 1, map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(2), "availableReplicas": int64(3)}),
+			health: yagov1alpha1.HealthProgressing,
+		},
+		{
+			name: "replicas not available yet",
+			obj: liveObject("DeploymentConfig", 1, map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(3), "availableReplicas": int64(1)}),
+			health: yagov1alpha1.HealthProgressing,
+		},
+		{
+			name: "progress deadline exceeded",
+			obj: liveObject("Deployment", 1, map[string]interface{}{"replicas": int64(3)},
+				map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(1), "conditions": conditionsOf(
+					map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"})}),
+			health: yagov1alpha1.HealthDegraded,
+		},
+		{
+			name: "stateful set counts ready replicas",
+			obj: liveObject("StatefulSet", 1, map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(2), "availableReplicas": int64(2), "readyReplicas": int64(1)}),
+			health: yagov1alpha1.HealthProgressing,
+		},
+		{
+			name: "ready stateful set",
+			obj: liveObject("StatefulSet", 1, map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(2), "readyReplicas": int64(2)}),
+			health: yagov1alpha1.HealthHealthy,
+		},
+		{
+			name: "daemon set being scheduled",
+			obj: liveObject("DaemonSet", 1, map[string]interface{}{},
+				map[string]interface{}{"observedGeneration": int64(1), "desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(2)}),
+			health: yagov1alpha1.HealthProgressing,
+		},
+		{
+			name: "rolled out daemon set",
+			obj: liveObject("DaemonSet", 1, map[string]interface{}{},
+				map[string]interface{}{"observedGeneration": int64(1), "desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(3)}),
+			health: yagov1alpha1.HealthHealthy,
+		},
+	}
+	for _, tt := range tests {
+		if health, message := rolloutHealth(tt.obj); health != tt.health {
+			t.Errorf("%s: rolloutHealth() = %v (%s), want %v", tt.name, health, message, tt.health)
+		}
+	}
+}
+
+func TestJobHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		health yagov1alpha1.HealthStatus
+	}{
+		{
+			name:   "running job",
+			obj:    liveObject("Job", 1, map[string]interface{}{}, map[string]interface{}{"active": int64(1)}),
+			health: yagov1alpha1.HealthProgressing,
+		},
+		{
+			name: "complete job",
+			obj: liveObject("Job", 1, map[string]interface{}{}, map[string]interface{}{"conditions": conditionsOf(
+				map[string]interface{}{"type": "Complete", "status": "True"})}),
+			health: yagov1alpha1.HealthHealthy,
+		},
+		{
+			name: "failed job",
+			obj: liveObject("Job", 1, map[string]interface{}{}, map[string]interface{}{"conditions": conditionsOf(
+				map[string]interface{}{"type": "Failed", "status": "True", "message": "BackoffLimitExceeded"})}),
+			health: yagov1alpha1.HealthDegraded,
+		},
+		{
+			name:   "enough completions",
+			obj:    liveObject("Job", 1, map[string]interface{}{"completions": int64(2)}, map[string]interface{}{"succeeded": int64(2)}),
+			health: yagov1alpha1.HealthHealthy,
+		},
+		{
+			name:   "missing completions",
+			obj:    liveObject("Job", 1, map[string]interface{}{"completions": int64(3)}, map[string]interface{}{"succeeded": int64(2)}),
+			health: yagov1alpha1.HealthProgressing,
+		},
+	}
+	for _, tt := range tests {
+		if health, message := jobHealth(tt.obj); health != tt.health {
+			t.Errorf("%s: jobHealth() = %v (%s), want %v", tt.name, health, message, tt.health)
+		}
+	}
+}
+
+func TestServiceHealth(t *testing.T) {
+	endpoints := func(name string, addresses ...string) *corev1.Endpoints {
+		e := &corev1.Endpoints{}
+		e.Name, e.Namespace = name, "target"
+		subset := corev1.EndpointSubset{}
+		for _, ip := range addresses {
+			subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: ip})
+		}
+		e.Subsets = []corev1.EndpointSubset{subset}
+		return e
+	}
+	service := func(name string, selector map[string]interface{}) *unstructured.Unstructured {
+		obj := liveObject("Service", 1, map[string]interface{}{"selector": selector}, map[string]interface{}{})
+		obj.SetName(name)
+		obj.SetNamespace("target")
+		return obj
+	}
+	selector := map[string]interface{}{"app": "web"}
+	objects := []runtime.Object{endpoints("ready", "10.0.0.1"), endpoints("unready")}
+	r := &ReconcileYago{objects: fake.NewFakeClientWithScheme(testScheme(t), objects...)}
+	tests := []struct {
+		name    string
+		service *unstructured.Unstructured
+		health  yagov1alpha1.HealthStatus
+	}{
+		{name: "service without selector", service: service("external", nil), health: yagov1alpha1.HealthHealthy},
+		{name: "ready endpoints", service: service("ready", selector), health: yagov1alpha1.HealthHealthy},
+		{name: "no ready endpoints", service: service("unready", selector), health: yagov1alpha1.HealthProgressing},
+		{name: "endpoints not created yet", service: service("new", selector), health: yagov1alpha1.HealthProgressing},
+	}
+	for _, tt := range tests {
+		health, message, err := r.serviceHealth(tt.service)
+		if err != nil {
+			t.Errorf("%s: serviceHealth() error = %v", tt.name, err)
+			continue
+		}
+		if health != tt.health {
+			t.Errorf("%s: serviceHealth() = %v (%s), want %v", tt.name, health, message, tt.health)
+		}
+	}
+}
+
+func TestOverallHealth(t *testing.T) {
+	object := func(kind string, health yagov1alpha1.HealthStatus) yagov1alpha1.ManagedObject {
+		return yagov1alpha1.ManagedObject{Kind: kind, Name: "example", Health: health}
+	}
+	tests := []struct {
+		name        string
+		inventory   []yagov1alpha1.ManagedObject
+		wantHealth  yagov1alpha1.HealthStatus
+		wantMessage string
+	}{
+		{name: "empty inventory", wantHealth: yagov1alpha1.HealthHealthy},
+		{
+			name:       "healthy objects",
+			inventory:  []yagov1alpha1.ManagedObject{object("Service", yagov1alpha1.HealthHealthy), object("ConfigMap", yagov1alpha1.HealthHealthy)},
+			wantHealth: yagov1alpha1.HealthHealthy,
+		},
+		{
+			name: "first progressing object is reported",
+			inventory: []yagov1alpha1.ManagedObject{object("Service", yagov1alpha1.HealthHealthy),
+				object("Deployment", yagov1alpha1.HealthProgressing), object("Job", yagov1alpha1.HealthProgressing)},
+			wantHealth:  yagov1alpha1.HealthProgressing,
+			wantMessage: "Deployment example is Progressing",
+		},
+		{
+			name: "degraded object outweighs progressing ones",
+			inventory: []yagov1alpha1.ManagedObject{object("Deployment", yagov1alpha1.HealthProgressing),
+				object("Job", yagov1alpha1.HealthDegraded)},
+			wantHealth:  yagov1alpha1.HealthDegraded,
+			wantMessage: "Job example is Degraded",
+		},
+		{
+			name:        "missing object is degraded",
+			inventory:   []yagov1alpha1.ManagedObject{object("ConfigMap", yagov1alpha1.HealthMissing)},
+			wantHealth:  yagov1alpha1.HealthDegraded,
+			wantMessage: "ConfigMap example is Missing",
+		},
+	}
+	for _, tt := range tests {
+		health, message := overallHealth(tt.inventory)
+		if health != tt.wantHealth || message != tt.wantMessage {
+			t.Errorf("%s: overallHealth() = %v, %q, want %v, %q", tt.name, health, message, tt.wantHealth, tt.wantMessage)
+		}
+	}
+}
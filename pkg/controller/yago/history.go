@@ -0,0 +1,75 @@
+package yago
+
+import (
+	"fmt"
+	"strings"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultHistoryLimit is the number of sync attempts kept if spec.historyLimit is not set
+const defaultHistoryLimit = 10
+
+// syncStats counts the changes made by a sync
+type syncStats struct {
+	created, updated int32
+}
+
+func (s *syncStats) changed() bool {
+	return s.created+s.updated > 0
+}
+
+// newSyncRecord starts the record of a sync attempt of commit
+func newSyncRecord(commit string, c *object.Commit) yagov1alpha1.SyncRecord {
+	record := yagov1alpha1.SyncRecord{Commit: commit, StartedAt: metav1.Now()}
+	if c != nil {
+		record.CommitMessage = strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0]
+		record.Author = fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email)
+	}
+	return record
+}
+
+// recordSync finishes the record of a sync attempt and adds it to the history. Attempts that
+// neither changed anything nor differ from the previous one are not recorded, so that periodic
+// reconciles do not push the interesting attempts out of the history.
+func recordSync(
+	instance *yagov1alpha1.Yago,
+	record yagov1alpha1.SyncRecord,
+	stats *syncStats,
+	requested bool,
+	syncErr error) {
+
+	record.FinishedAt = metav1.Now()
+	record.Created, record.Updated = stats.created, stats.updated
+	record.Outcome = yagov1alpha1.SyncSucceeded
+	if isWaiting(syncErr) {
+		record.Outcome = yagov1alpha1.SyncInProgress
+	} else if syncErr != nil {
+		record.Outcome = yagov1alpha1.SyncFailed
+		record.Error = syncErr.Error()
+	}
+
+	history := instance.Status.History
+	if len(history) > 0 && !requested && !stats.changed() {
+		last := &history[0]
+		if last.Commit == record.Commit && last.Outcome == record.Outcome && last.Error == record.Error {
+			if record.Outcome == yagov1alpha1.SyncFailed {
+				// Retries of a failing sync only move the finish time of its record
+				last.FinishedAt = record.FinishedAt
+			}
+			return
+		}
+	}
+
+	limit := defaultHistoryLimit
+	if instance.Spec.HistoryLimit != nil {
+		limit = int(*instance.Spec.HistoryLimit)
+	}
+	history = append([]yagov1alpha1.SyncRecord{record}, history...)
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	instance.Status.History = history
+}
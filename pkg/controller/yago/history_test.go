@@ -0,0 +1,126 @@
+package yago
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordSync(t *testing.T) {
+	longAgo := metav1.NewTime(time.Date(2020, time.January, 6, 12, 0, 0, 0, time.UTC))
+	attempt := func(commit string, outcome yagov1alpha1.SyncOutcome, message string) yagov1alpha1.SyncRecord {
+		return yagov1alpha1.SyncRecord{Commit: commit, Outcome: outcome, Error: message, StartedAt: longAgo, FinishedAt: longAgo}
+	}
+	limit := int32(2)
+	tests := []struct {
+		name        string
+		history     []yagov1alpha1.SyncRecord
+		limit       *int32
+		commit      string
+		stats       syncStats
+		requested   bool
+		syncErr     error
+		wantLen     int
+		wantOutcome yagov1alpha1.SyncOutcome
+		wantMoved   bool
+	}{
+		{name: "first sync", commit: "a", wantLen: 1, wantOutcome: yagov1alpha1.SyncSucceeded},
+		{
+			name:        "unchanged resync is not recorded",
+			history:     []yagov1alpha1.SyncRecord{attempt("a", yagov1alpha1.SyncSucceeded, "")},
+			commit:      "a",
+			wantLen:     1,
+			wantOutcome: yagov1alpha1.SyncSucceeded,
+		},
+		{
+			name:        "resync that changed objects is recorded",
+			history:     []yagov1alpha1.SyncRecord{attempt("a", yagov1alpha1.SyncSucceeded, "")},
+			commit:      "a",
+			stats:       syncStats{updated: 1},
+			wantLen:     2,
+			wantOutcome: yagov1alpha1.SyncSucceeded,
+		},
+		{
+			name:        "requested resync is recorded",
+			history:     []yagov1alpha1.SyncRecord{attempt("a", yagov1alpha1.SyncSucceeded, "")},
+			commit:      "a",
+			requested:   true,
+			wantLen:     2,
+			wantOutcome: yagov1alpha1.SyncSucceeded,
+		},
+		{
+			name:        "new commit is recorded",
+			history:     []yagov1alpha1.SyncRecord{attempt("a", yagov1alpha1.SyncSucceeded, "")},
+			commit:      "b",
+			wantLen:     2,
+			wantOutcome: yagov1alpha1.SyncSucceeded,
+		},
+		{
+			name:        "retry of a failing sync moves its finish time",
+			history:     []yagov1alpha1.SyncRecord{attempt("a", yagov1alpha1.SyncFailed, "boom")},
+			commit:      "a",
+			syncErr:     errors.New("boom"),
+			wantLen:     1,
+			wantOutcome: yagov1alpha1.SyncFailed,
+			wantMoved:   true,
+		},
+		{
+			name:        "sync failing differently is recorded",
+			history:     []yagov1alpha1.SyncRecord{attempt("a", yagov1alpha1.SyncFailed, "boom")},
+			commit:      "a",
+			syncErr:     errors.New("bang"),
+			wantLen:     2,
+			wantOutcome: yagov1alpha1.SyncFailed,
+		},
+		{
+			name:        "waiting sync is in progress",
+			history:     []yagov1alpha1.SyncRecord{attempt("a", yagov1alpha1.SyncSucceeded, "")},
+			commit:      "b",
+			syncErr:     waitingFor("waiting for sync wave %d", 1),
+			wantLen:     2,
+			wantOutcome: yagov1alpha1.SyncInProgress,
+		},
+		{
+			name:        "still waiting sync is not recorded again",
+			history:     []yagov1alpha1.SyncRecord{attempt("b", yagov1alpha1.SyncInProgress, "")},
+			commit:      "b",
+			syncErr:     waitingFor("waiting for sync wave %d", 1),
+			wantLen:     1,
+			wantOutcome: yagov1alpha1.SyncInProgress,
+		},
+		{
+			name: "history is cut at the limit",
+			history: []yagov1alpha1.SyncRecord{attempt("b", yagov1alpha1.SyncSucceeded, ""),
+				attempt("a", yagov1alpha1.SyncSucceeded, "")},
+			limit:       &limit,
+			commit:      "c",
+			wantLen:     2,
+			wantOutcome: yagov1alpha1.SyncSucceeded,
+		},
+	}
+	for _, tt := range tests {
+		instance := &yagov1alpha1.Yago{}
+		instance.Spec.HistoryLimit = tt.limit
+		instance.Status.History = tt.history
+		stats := tt.stats
+		recordSync(instance, newSyncRecord(tt.commit, nil), &stats, tt.requested, tt.syncErr)
+		history := instance.Status.History
+		if len(history) != tt.wantLen {
+			t.Errorf("%s: recordSync() history has %d records, want %d", tt.name, len(history), tt.wantLen)
+			continue
+		}
+		if history[0].Commit != tt.commit || history[0].Outcome != tt.wantOutcome {
+			t.Errorf("%s: recordSync() latest record = %s %s, want %s %s",
+				tt.name, history[0].Commit, history[0].Outcome, tt.commit, tt.wantOutcome)
+		}
+		// A kept record only has its finish time moved by retries of a failing sync
+		if kept := history[0].StartedAt.Equal(&longAgo); kept {
+			if moved := !history[0].FinishedAt.Equal(&longAgo); moved != tt.wantMoved {
+				t.Errorf("%s: recordSync() moved the finish time = %v, want %v", tt.name, moved, tt.wantMoved)
+			}
+		}
+	}
+}
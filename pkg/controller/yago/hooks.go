@@ -0,0 +1,238 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// hookTimeout is how long a hook Job may run before it is considered failed
+var hookTimeout = time.Minute * 10
+
+var jobGroupKind = schema.GroupKind{Group: "batch", Kind: "Job"}
+
+// splitHooks separates hook manifests, by phase, from the objects to apply
+func splitHooks(manifests []*manifest) ([]*manifest, map[yagov1alpha1.HookPhase][]*manifest) {
+	var objects []*manifest
+	hooks := map[yagov1alpha1.HookPhase][]*manifest{}
+	for _, m := range manifests {
+		if m.hook == "" {
+			objects = append(objects, m)
+			continue
+		}
+		hooks[m.hook] = append(hooks[m.hook], m)
+	}
+	return objects, hooks
+}
+
+// pruneHookStatus drops the outcomes of hooks run for other commits, or before the sync request
+// being handled, so that those hooks run again. The request is recorded on each hook, so retries
+// of a requested sync do not run its hooks again.
+func pruneHookStatus(status *yagov1alpha1.YagoStatus, commit string, request string) {
+	hooks := status.Hooks[:0]
+	for _, h := range status.Hooks {
+		if h.Commit == commit && h.Request == request {
+			hooks = append(hooks, h)
+		}
+	}
+	status.Hooks = hooks
+}
+
+func findHookStatus(status *yagov1alpha1.YagoStatus, phase yagov1alpha1.HookPhase, name string) *yagov1alpha1.HookStatus {
+	for i := range status.Hooks {
+		if status.Hooks[i].Phase == phase && status.Hooks[i].Name == name {
+			return &status.Hooks[i]
+		}
+	}
+	return nil
+}
+
+// runHooks runs the hooks of a phase one after the other, skipping hooks that already ran for
+// commit. The Job of a hook is only started here, a waitingError is returned until it finishes
+// and its result is checked again on later reconciles. It returns an error if any hook fails.
+func (r *ReconcileYago) runHooks(
+	instance *yagov1alpha1.Yago,
+	phase yagov1alpha1.HookPhase,
+	hooks []*manifest,
+	commit string,
+	request string,
+	namespace string,
+	reqLogger logr.Logger) error {
+
+	for _, h := range hooks {
+		name := h.obj.GetName()
+		hs := findHookStatus(&instance.Status, phase, name)
+		if hs == nil || hs.Commit != commit || hs.Request != request {
+			reqLogger.Info("Starting hook", "Phase", phase, "Hook", name)
+			started, err := r.startHook(instance, h, namespace)
+			if err != nil || !started {
+				return err
+			}
+			status := yagov1alpha1.HookStatus{Name: name, Phase: phase, Commit: commit, Request: request,
+				Result: yagov1alpha1.HookResultRunning}
+			if hs != nil {
+				*hs = status
+			} else {
+				instance.Status.Hooks = append(instance.Status.Hooks, status)
+			}
+			if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+				return err
+			}
+			return waitingFor("waiting for %s hook %s", phase, name)
+		}
+		if hs.Result == yagov1alpha1.HookResultRunning {
+			result, message, err := r.checkHook(h, namespace)
+			if err != nil {
+				return err
+			}
+			if result == yagov1alpha1.HookResultRunning {
+				return waitingFor("waiting for %s hook %s", phase, name)
+			}
+			reqLogger.Info("Hook finished", "Phase", phase, "Hook", name, "Result", result)
+			hs.Result, hs.Message = result, message
+			if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+				return err
+			}
+		}
+		if hs.Result == yagov1alpha1.HookResultFailed {
+			return fmt.Errorf("%s hook %s failed: %s", phase, hs.Name, hs.Message)
+		}
+	}
+	return nil
+}
+
+// runSyncFailHooks runs the SyncFail hooks after a failed sync. Their failures are only logged,
+// the error of the sync is the one reported.
+func (r *ReconcileYago) runSyncFailHooks(
+	instance *yagov1alpha1.Yago,
+	hooks []*manifest,
+	commit string,
+	request string,
+	namespace string,
+	reqLogger logr.Logger) {
+
+	err := r.runHooks(instance, yagov1alpha1.HookSyncFail, hooks, commit, request, namespace, reqLogger)
+	if isWaiting(err) {
+		reqLogger.Info("SyncFail hooks started", "Reason", err.Error())
+	} else if err != nil {
+		reqLogger.Error(err, "SyncFail hooks failed")
+	}
+}
+
+// startHook creates the Job of a hook. A Job left over from a previous run is deleted first, and
+// the hook is only started on a later call, once that Job is gone.
+func (r *ReconcileYago) startHook(instance *yagov1alpha1.Yago, h *manifest, namespace string) (bool, error) {
+	job := h.obj.DeepCopy()
+	job.SetNamespace(namespace)
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(h.gvk)
+	err := r.objects.Get(context.TODO(), types.NamespacedName{Name: job.GetName(), Namespace: namespace}, existing)
+	if err == nil {
+		// Jobs cannot run again, the Job of the previous run has to be replaced
+		if !deletePolicies(h.obj)[yagov1alpha1.DeletePolicyBeforeHookCreation] {
+			return false, fmt.Errorf("hook Job %s already exists and its delete policy does not include %s",
+				job.GetName(), yagov1alpha1.DeletePolicyBeforeHookCreation)
+		}
+		if existing.GetDeletionTimestamp() == nil {
+			err := r.objects.Delete(context.TODO(), existing, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+		}
+		return false, waitingFor("waiting for the previous hook Job %s to be deleted", job.GetName())
+	} else if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	if ownable(instance, namespace) {
+		if err := controllerutil.SetControllerReference(instance, job, r.scheme); err != nil {
+			return false, err
+		}
+	}
+	setTracking(instance, job)
+	if err := r.objects.Create(context.TODO(), job); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkHook reads the result of the Job of a running hook, applying the delete policies once it
+// finished. Jobs running for longer than hookTimeout have failed.
+func (r *ReconcileYago) checkHook(h *manifest, namespace string) (yagov1alpha1.HookResult, string, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(h.gvk)
+	err := r.objects.Get(context.TODO(), types.NamespacedName{Name: h.obj.GetName(), Namespace: namespace}, live)
+	if errors.IsNotFound(err) {
+		return yagov1alpha1.HookResultFailed, "hook Job was deleted before it finished", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	var result yagov1alpha1.HookResult
+	var message string
+	switch health, msg := jobHealth(live); health {
+	case yagov1alpha1.HealthHealthy:
+		result = yagov1alpha1.HookResultSucceeded
+	case yagov1alpha1.HealthDegraded:
+		result, message = yagov1alpha1.HookResultFailed, msg
+	default:
+		if time.Since(live.GetCreationTimestamp().Time) < hookTimeout {
+			return yagov1alpha1.HookResultRunning, "", nil
+		}
+		result, message = yagov1alpha1.HookResultFailed, fmt.Sprintf("did not finish within %s", hookTimeout)
+	}
+	policies := deletePolicies(h.obj)
+	if (result == yagov1alpha1.HookResultSucceeded && policies[yagov1alpha1.DeletePolicyHookSucceeded]) ||
+		(result == yagov1alpha1.HookResultFailed && policies[yagov1alpha1.DeletePolicyHookFailed]) {
+		if err := r.objects.Delete(context.TODO(), live, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return "", "", err
+		}
+	}
+	return result, message, nil
+}
+
+// deletePolicies parses the delete policy annotation of a hook
+func deletePolicies(obj *unstructured.Unstructured) map[string]bool {
+	value, ok := obj.GetAnnotations()[yagov1alpha1.HookDeletePolicyAnnotation]
+	if !ok {
+		value = yagov1alpha1.DeletePolicyBeforeHookCreation
+	}
+	policies := map[string]bool{}
+	for _, p := range strings.Split(value, ",") {
+		policies[strings.TrimSpace(p)] = true
+	}
+	return policies
+}
+
+// deleteAndWait deletes an object and waits until it is gone. The propagation policy tells
+// whether its dependents are deleted along with it.
+func (r *ReconcileYago) deleteAndWait(obj *unstructured.Unstructured, propagation metav1.DeletionPropagation) error {
+	if err := r.objects.Delete(context.TODO(), obj, client.PropagationPolicy(propagation)); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return wait.Poll(retryInterval, timeout, func() (done bool, err error) {
+		getErr := r.objects.Get(context.TODO(), types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, obj.DeepCopy())
+		if getErr != nil {
+			if errors.IsNotFound(getErr) {
+				return true, nil
+			}
+			return false, getErr
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,122 @@
+package yago
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clientCache holds the clients of remote clusters and impersonated users, so that they are not
+// built on every reconcile
+type clientCache struct {
+	config   *rest.Config
+	scheme   *runtime.Scheme
+	mapper   meta.RESTMapper
+	lock     sync.Mutex
+	clusters map[string]*remoteCluster
+	clients  map[string]client.Client
+}
+
+func newClientCache(config *rest.Config, scheme *runtime.Scheme, mapper meta.RESTMapper) *clientCache {
+	return &clientCache{
+		config:   config,
+		scheme:   scheme,
+		mapper:   mapper,
+		clusters: map[string]*remoteCluster{},
+		clients:  map[string]client.Client{},
+	}
+}
+
+// get returns a client of the cluster, acting as username unless it is empty. The local cluster
+// has no cluster.
+func (c *clientCache) get(cluster *remoteCluster, username string) (client.Client, error) {
+	config, mapper, key := c.config, c.mapper, "|"+username
+	if cluster != nil {
+		config, mapper, key = cluster.config, cluster.mapper, cluster.key+key
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cl, ok := c.clients[key]; ok {
+		return cl, nil
+	}
+	config = rest.CopyConfig(config)
+	if username != "" {
+		config.Impersonate = rest.ImpersonationConfig{UserName: username}
+	}
+	cl, err := client.New(config, client.Options{Scheme: c.scheme, Mapper: mapper})
+	if err != nil {
+		return nil, err
+	}
+	c.clients[key] = cl
+	return cl, nil
+}
+
+// forget drops the clients of a cluster that was replaced
+func (c *clientCache) forget(cluster *remoteCluster) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key := range c.clients {
+		if strings.HasPrefix(key, cluster.key+"|") {
+			delete(c.clients, key)
+		}
+	}
+}
+
+// evict drops the cluster of the Yago named namespace/name and its clients, once the Yago is gone
+// or no longer names a kubeconfig
+func (c *clientCache) evict(name string) {
+	c.lock.Lock()
+	cluster, ok := c.clusters[name]
+	delete(c.clusters, name)
+	c.lock.Unlock()
+	if ok {
+		c.forget(cluster)
+	}
+}
+
+// serviceAccountUsername is the user a ServiceAccount authenticates as
+func serviceAccountUsername(namespace, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// forInstance returns a reconciler that reads and writes the managed objects of instance in the
+// cluster of spec.kubeConfig, as the ServiceAccount named by spec.serviceAccountName so that its
+// RBAC bounds what the repository can do. The Yago itself is still read and updated in the
+// operator's cluster with the operator's own permissions.
+func (r *ReconcileYago) forInstance(instance *yagov1alpha1.Yago) (*ReconcileYago, error) {
+	if instance.Spec.KubeConfig == nil {
+		r.clients.evict(types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}.String())
+	}
+	if instance.Spec.ServiceAccountName == "" && instance.Spec.KubeConfig == nil {
+		return r, nil
+	}
+	var cluster *remoteCluster
+	if instance.Spec.KubeConfig != nil {
+		var err error
+		if cluster, err = r.remoteCluster(instance); err != nil {
+			return nil, err
+		}
+	}
+	var username string
+	if instance.Spec.ServiceAccountName != "" {
+		username = serviceAccountUsername(instance.Namespace, instance.Spec.ServiceAccountName)
+	}
+	objects, err := r.clients.get(cluster, username)
+	if err != nil {
+		return nil, err
+	}
+	scoped := *r
+	scoped.objects = objects
+	if cluster != nil {
+		scoped.mapper = cluster.mapper
+		scoped.cluster = cluster
+	}
+	return &scoped, nil
+}
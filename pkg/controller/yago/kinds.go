@@ -0,0 +1,94 @@
+package yago
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Operator-wide kind policy, which Yagos can narrow down but never widen
+var (
+	allowedKinds = flag.String("allowed-kinds", "",
+		"Comma separated Kind.group patterns every Yago is limited to, on top of its spec.allowedKinds, every kind if empty")
+	deniedKinds = flag.String("denied-kinds", "",
+		"Comma separated Kind.group patterns no Yago may apply, on top of its spec.deniedKinds")
+)
+
+// parseKindPatterns parses comma separated Kind.group patterns. A bare Kind is in the core group
+// and * alone matches every kind of every group.
+func parseKindPatterns(value string) []yagov1alpha1.KindPattern {
+	var patterns []yagov1alpha1.KindPattern
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if p == "*" {
+			patterns = append(patterns, yagov1alpha1.KindPattern{Group: "*", Kind: "*"})
+			continue
+		}
+		parts := strings.SplitN(p, ".", 2)
+		pattern := yagov1alpha1.KindPattern{Kind: parts[0]}
+		if len(parts) == 2 {
+			pattern.Group = parts[1]
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// matchesKind tells whether any of the patterns matches gk
+func matchesKind(patterns []yagov1alpha1.KindPattern, gk schema.GroupKind) (bool, error) {
+	for _, p := range patterns {
+		group, err := path.Match(p.Group, gk.Group)
+		if err != nil {
+			return false, fmt.Errorf("invalid group pattern %q: %v", p.Group, err)
+		}
+		kind, err := path.Match(p.Kind, gk.Kind)
+		if err != nil {
+			return false, fmt.Errorf("invalid kind pattern %q: %v", p.Kind, err)
+		}
+		if group && kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkKinds fails if any manifest has a kind the instance may not apply, listing every offending file.
+// A kind is allowed if it matches the allowed patterns of both the operator and the instance, where
+// there are any, and no denied pattern of either, so a Yago can only narrow down what the operator allows.
+func checkKinds(instance *yagov1alpha1.Yago, manifests []*manifest) error {
+	allowed := [][]yagov1alpha1.KindPattern{parseKindPatterns(*allowedKinds), instance.Spec.AllowedKinds}
+	denied := append(parseKindPatterns(*deniedKinds), instance.Spec.DeniedKinds...)
+	var offending []string
+	for _, m := range manifests {
+		gk := m.gvk.GroupKind()
+		isAllowed := true
+		for _, patterns := range allowed {
+			if len(patterns) == 0 {
+				continue
+			}
+			matches, err := matchesKind(patterns, gk)
+			if err != nil {
+				return err
+			}
+			isAllowed = isAllowed && matches
+		}
+		isDenied, err := matchesKind(denied, gk)
+		if err != nil {
+			return err
+		}
+		if !isAllowed || isDenied {
+			offending = append(offending, fmt.Sprintf("%s (%s)", m.path, gk))
+		}
+	}
+	if len(offending) > 0 {
+		return fmt.Errorf("kinds not allowed: %s", strings.Join(offending, ", "))
+	}
+	return nil
+}
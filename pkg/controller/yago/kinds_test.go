@@ -0,0 +1,74 @@
+package yago
+
+import (
+	"testing"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseKindPatterns(t *testing.T) {
+	got := parseKindPatterns(" *, Secret ,*.rbac.authorization.k8s.io,,Deployment.apps")
+	want := []yagov1alpha1.KindPattern{
+		{Group: "*", Kind: "*"},
+		{Kind: "Secret"},
+		{Group: "rbac.authorization.k8s.io", Kind: "*"},
+		{Group: "apps", Kind: "Deployment"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseKindPatterns() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseKindPatterns()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCheckKinds(t *testing.T) {
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	secret := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	role := schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"}
+	tests := []struct {
+		name            string
+		operatorAllowed string
+		operatorDenied  string
+		allowed         []yagov1alpha1.KindPattern
+		denied          []yagov1alpha1.KindPattern
+		gvk             schema.GroupVersionKind
+		wantErr         bool
+	}{
+		{name: "no lists", gvk: role},
+		{name: "allowed by the Yago", allowed: []yagov1alpha1.KindPattern{{Group: "apps", Kind: "*"}}, gvk: deployment},
+		{name: "not allowed by the Yago", allowed: []yagov1alpha1.KindPattern{{Group: "apps", Kind: "*"}}, gvk: secret, wantErr: true},
+		{name: "denied by the Yago", denied: []yagov1alpha1.KindPattern{{Kind: "Secret"}}, gvk: secret, wantErr: true},
+		{name: "denied by the operator", operatorDenied: "Secret", gvk: secret, wantErr: true},
+		{name: "operator deny list applies along with the Yago's",
+			operatorDenied: "*.rbac.authorization.k8s.io", denied: []yagov1alpha1.KindPattern{{Kind: "Secret"}}, gvk: role, wantErr: true},
+		{name: "Yago cannot allow what the operator denies",
+			operatorDenied: "Secret", allowed: []yagov1alpha1.KindPattern{{Group: "*", Kind: "*"}}, gvk: secret, wantErr: true},
+		{name: "Yago cannot widen the operator allow list",
+			operatorAllowed: "*.apps", allowed: []yagov1alpha1.KindPattern{{Kind: "Secret"}}, gvk: secret, wantErr: true},
+		{name: "Yago can narrow the operator allow list",
+			operatorAllowed: "*.apps,Secret", allowed: []yagov1alpha1.KindPattern{{Group: "apps", Kind: "Deployment"}}, gvk: secret, wantErr: true},
+		{name: "allowed by both",
+			operatorAllowed: "*.apps,Secret", allowed: []yagov1alpha1.KindPattern{{Group: "apps", Kind: "Deployment"}}, gvk: deployment},
+		{name: "allowed by the operator", operatorAllowed: "*.apps", gvk: deployment},
+		{name: "invalid pattern", allowed: []yagov1alpha1.KindPattern{{Group: "[", Kind: "*"}}, gvk: deployment, wantErr: true},
+	}
+	defer func(allowed, denied string) {
+		*allowedKinds, *deniedKinds = allowed, denied
+	}(*allowedKinds, *deniedKinds)
+	for _, tt := range tests {
+		*allowedKinds, *deniedKinds = tt.operatorAllowed, tt.operatorDenied
+		instance := &yagov1alpha1.Yago{}
+		instance.Spec.AllowedKinds, instance.Spec.DeniedKinds = tt.allowed, tt.denied
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(tt.gvk)
+		err := checkKinds(instance, []*manifest{{path: "object.yaml", obj: obj, gvk: tt.gvk}})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkKinds() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package yago
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// manifest is an object decoded from the repository along with the file it was read from
+type manifest struct {
+	path string
+	obj  *unstructured.Unstructured
+	gvk  schema.GroupVersionKind
+	wave int32
+	// namespace the object is applied in, empty for cluster-scoped objects
+	namespace string
+	// hook is the phase hook manifests run in, empty for regular objects
+	hook yagov1alpha1.HookPhase
+	// conflict is the namespace/name of another Yago managing the object, which is then skipped
+	conflict string
+	options  syncOptions
+}
+
+// decodeManifests decodes every file of the tree into an object, in tree iteration order
+func decodeManifests(tree *object.Tree) ([]*manifest, error) {
+	var manifests []*manifest
+	filesIter := tree.Files()
+	defer filesIter.Close()
+	for {
+		f, err := filesIter.Next()
+		if err == io.EOF {
+			return manifests, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		cont, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+
+		unst := &unstructured.Unstructured{}
+		_, gvk, err := deserializer.Decode([]byte(cont), nil, unst)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %v", f.Name, err)
+		}
+		if unst.GetName() == "" {
+			return nil, fmt.Errorf("%s has no metadata.name", f.Name)
+		}
+		m := &manifest{path: f.Name, obj: unst, gvk: *gvk}
+		if wave, ok := unst.GetAnnotations()[yagov1alpha1.SyncWaveAnnotation]; ok {
+			w, err := strconv.ParseInt(wave, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s has an invalid sync wave %q", f.Name, wave)
+			}
+			m.wave = int32(w)
+		}
+		if options, ok := unst.GetAnnotations()[yagov1alpha1.SyncOptionsAnnotation]; ok {
+			if m.options, err = parseSyncOptions(options); err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+		}
+		if hook, ok := unst.GetAnnotations()[yagov1alpha1.HookAnnotation]; ok {
+			switch phase := yagov1alpha1.HookPhase(hook); phase {
+			case yagov1alpha1.HookPreSync, yagov1alpha1.HookPostSync, yagov1alpha1.HookSyncFail:
+				m.hook = phase
+			default:
+				return nil, fmt.Errorf("%s has an unknown hook phase %q", f.Name, hook)
+			}
+			if gvk.GroupKind() != jobGroupKind {
+				return nil, fmt.Errorf("%s is a hook but not a Job", f.Name)
+			}
+		}
+		manifests = append(manifests, m)
+	}
+}
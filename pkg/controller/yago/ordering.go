@@ -0,0 +1,164 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// kindPriority orders kinds so that objects are applied after the objects they depend on.
+// Kinds not listed here are applied last.
+var kindPriority = map[string]int{
+	"CustomResourceDefinition": 0,
+	"Namespace":                1,
+	"ServiceAccount":           2,
+	"Role":                     2,
+	"ClusterRole":              2,
+	"RoleBinding":              2,
+	"ClusterRoleBinding":       2,
+	"ConfigMap":                3,
+	"Secret":                   3,
+	"PersistentVolumeClaim":    3,
+	"Service":                  4,
+	"Deployment":               5,
+	"DeploymentConfig":         5,
+	"StatefulSet":              5,
+	"DaemonSet":                5,
+	"ReplicaSet":               5,
+	"ReplicationController":    5,
+	"Pod":                      5,
+	"Job":                      5,
+	"CronJob":                  5,
+	"BuildConfig":              5,
+}
+
+const otherKindsPriority = 6
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+func priorityOf(gvk schema.GroupVersionKind) int {
+	if p, ok := kindPriority[gvk.Kind]; ok {
+		return p
+	}
+	return otherKindsPriority
+}
+
+// sortManifests orders manifests by sync wave and by kind priority within a wave, keeping the
+// tree order otherwise
+func sortManifests(manifests []*manifest) {
+	sort.SliceStable(manifests, func(i, j int) bool {
+		if manifests[i].wave != manifests[j].wave {
+			return manifests[i].wave < manifests[j].wave
+		}
+		return priorityOf(manifests[i].gvk) < priorityOf(manifests[j].gvk)
+	})
+}
+
+// syncWave is a set of manifests applied together
+type syncWave struct {
+	number    int32
+	manifests []*manifest
+}
+
+// groupWaves splits sorted manifests into waves
+func groupWaves(manifests []*manifest) []*syncWave {
+	var waves []*syncWave
+	for _, m := range manifests {
+		if len(waves) == 0 || waves[len(waves)-1].number != m.wave {
+			waves = append(waves, &syncWave{number: m.wave})
+		}
+		last := waves[len(waves)-1]
+		last.manifests = append(last.manifests, m)
+	}
+	return waves
+}
+
+func isCRD(gvk schema.GroupVersionKind) bool {
+	return gvk.GroupKind() == crdGroupKind
+}
+
+// waitingError is returned while a sync waits for the cluster to catch up, such as for a wave to
+// become ready. The sync is not failed but requeued, and picks up where it stopped on a later
+// reconcile: objects applied already are unchanged and left as they are.
+type waitingError struct {
+	message string
+}
+
+func (e *waitingError) Error() string {
+	return e.message
+}
+
+func waitingFor(format string, args ...interface{}) error {
+	return &waitingError{message: fmt.Sprintf(format, args...)}
+}
+
+func isWaiting(err error) bool {
+	_, ok := err.(*waitingError)
+	return ok
+}
+
+// crdKinds returns the kinds the CRDs among manifests define, in each of their versions
+func crdKinds(manifests []*manifest) map[schema.GroupVersionKind]bool {
+	kinds := map[schema.GroupVersionKind]bool{}
+	for _, m := range manifests {
+		if !isCRD(m.gvk) {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(m.obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(m.obj.Object, "spec", "names", "kind")
+		// apiextensions.k8s.io/v1beta1 CRDs may name a single version only
+		if version, _, _ := unstructured.NestedString(m.obj.Object, "spec", "version"); version != "" {
+			kinds[schema.GroupVersionKind{Group: group, Version: version, Kind: kind}] = true
+		}
+		versions, _, _ := unstructured.NestedSlice(m.obj.Object, "spec", "versions")
+		for _, v := range versions {
+			if version, ok := v.(map[string]interface{}); ok {
+				if name, ok := version["name"].(string); ok {
+					kinds[schema.GroupVersionKind{Group: group, Version: name, Kind: kind}] = true
+				}
+			}
+		}
+	}
+	return kinds
+}
+
+// waitForMapping returns a waitingError until the API server serves gvk. Only kinds defined by
+// CRDs of the same commit are waited for, any other kind the cluster does not know fails.
+func (r *ReconcileYago) waitForMapping(gvk schema.GroupVersionKind, defined map[schema.GroupVersionKind]bool) error {
+	_, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if _, limited := apiutil.DelayIfRateLimited(err); limited || meta.IsNoMatchError(err) && defined[gvk] {
+		return waitingFor("waiting for %s to be served", gvk)
+	}
+	return err
+}
+
+// waitForCRDEstablished returns a waitingError until the CRD of the given name is ready to serve
+// its kind. The CRD is read in the version of apiextensions the cluster prefers.
+func (r *ReconcileYago) waitForCRDEstablished(name string) error {
+	mapping, err := r.mapper.RESTMapping(crdGroupKind)
+	if err != nil {
+		return err
+	}
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(mapping.GroupVersionKind)
+	if err := r.objects.Get(context.TODO(), types.NamespacedName{Name: name}, crd); err != nil {
+		return err
+	}
+	conditions, _, err := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	if err != nil {
+		return err
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return nil
+		}
+	}
+	return waitingFor("waiting for CRD %s to be established", name)
+}
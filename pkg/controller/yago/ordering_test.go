@@ -0,0 +1,87 @@
+package yago
+
+import (
+	"testing"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func manifestOf(gvk schema.GroupVersionKind, object map[string]interface{}) *manifest {
+	obj := &unstructured.Unstructured{Object: object}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName("example")
+	return &manifest{path: "example.yaml", obj: obj, gvk: gvk}
+}
+
+func TestCRDKinds(t *testing.T) {
+	crd := schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+	crdV1beta1 := schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}
+	manifests := []*manifest{
+		manifestOf(crd, map[string]interface{}{"spec": map[string]interface{}{
+			"group":    "example.com",
+			"names":    map[string]interface{}{"kind": "Widget"},
+			"versions": []interface{}{map[string]interface{}{"name": "v1"}, map[string]interface{}{"name": "v2"}},
+		}}),
+		manifestOf(crdV1beta1, map[string]interface{}{"spec": map[string]interface{}{
+			"group":   "example.com",
+			"names":   map[string]interface{}{"kind": "Gadget"},
+			"version": "v1alpha1",
+		}}),
+		manifestOf(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, map[string]interface{}{}),
+	}
+	got := crdKinds(manifests)
+	want := []schema.GroupVersionKind{
+		{Group: "example.com", Version: "v1", Kind: "Widget"},
+		{Group: "example.com", Version: "v2", Kind: "Widget"},
+		{Group: "example.com", Version: "v1alpha1", Kind: "Gadget"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("crdKinds() = %v, want %v", got, want)
+	}
+	for _, gvk := range want {
+		if !got[gvk] {
+			t.Errorf("crdKinds() does not contain %s", gvk)
+		}
+	}
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	widget := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(configMap, meta.RESTScopeNamespace)
+	r := &ReconcileYago{mapper: mapper}
+	instance := &yagov1alpha1.Yago{}
+	instance.Namespace = "yago"
+	tests := []struct {
+		name    string
+		gvk     schema.GroupVersionKind
+		defined map[schema.GroupVersionKind]bool
+		wantErr bool
+	}{
+		{name: "served kind", gvk: configMap},
+		{name: "kind defined by a CRD of the commit", gvk: widget, defined: map[schema.GroupVersionKind]bool{widget: true}},
+		{name: "unknown kind", gvk: widget, wantErr: true},
+		{name: "unknown version of a kind defined by a CRD", gvk: widget,
+			defined: map[schema.GroupVersionKind]bool{{Group: "example.com", Version: "v2", Kind: "Widget"}: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := r.resolveNamespaces(instance, []*manifest{manifestOf(tt.gvk, map[string]interface{}{})}, tt.defined)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: resolveNamespaces() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil {
+			// Only kinds defined by a CRD of the commit are waited for
+			err = r.waitForMapping(tt.gvk, tt.defined)
+			if wantWaiting := tt.gvk == widget; isWaiting(err) != wantWaiting {
+				t.Errorf("%s: waitForMapping() error = %v, want waiting %v", tt.name, err, wantWaiting)
+			}
+		}
+	}
+	if err := r.waitForMapping(widget, nil); err == nil || isWaiting(err) {
+		t.Errorf("waitForMapping() of an unknown kind = %v, want an error", err)
+	}
+}
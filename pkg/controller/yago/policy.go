@@ -0,0 +1,255 @@
+package yago
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// policy is a guardrail every object of a matching kind is checked against. Policies are read
+// from the keys of ConfigMaps, as YAML documents such as:
+//
+//	name: trusted-registry
+//	mode: enforce
+//	kinds: [Deployment.apps, StatefulSet.apps]
+//	message: images must come from registry.example.com
+//	rules:
+//	- path: .spec.template.spec.containers[*].image
+//	  pattern: registry.example.com/*
+type policy struct {
+	Name string `json:"name"`
+	// Mode is enforce, failing the sync on violations, or warn, only reporting them
+	Mode yagov1alpha1.PolicyMode `json:"mode"`
+	// Kinds are the Kind.group patterns of the objects checked, every object if empty
+	Kinds   []string     `json:"kinds"`
+	Message string       `json:"message"`
+	Rules   []policyRule `json:"rules"`
+}
+
+// policyRule checks every field selected by a JSONPath expression. Fields that are absent are
+// not checked, so a rule on containers[*] holds for objects without containers.
+type policyRule struct {
+	Path string `json:"path"`
+	// Pattern is a glob pattern the fields must match, where * matches any text including /
+	Pattern string `json:"pattern,omitempty"`
+	// Forbidden are values the fields may not have
+	Forbidden []interface{} `json:"forbidden,omitempty"`
+	// Required are JSONPath expressions, relative to each field, of subfields it must have
+	Required []string `json:"required,omitempty"`
+}
+
+// Operator-wide policies, which every Yago is checked against whatever its own ConfigMaps say
+var policyConfigMaps = flag.String("policy-configmaps", "",
+	"Comma separated namespace/name of ConfigMaps whose policies every Yago is checked against, on top of its spec.policyConfigMaps")
+
+// loadPolicies reads the policies of the operator's policy ConfigMaps, then those of the policy
+// ConfigMaps of instance, each in key order
+func (r *ReconcileYago) loadPolicies(instance *yagov1alpha1.Yago) ([]*policy, error) {
+	var policies []*policy
+	for _, value := range strings.Split(*policyConfigMaps, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		parts := strings.Split(value, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid policy ConfigMap %q, expected namespace/name", value)
+		}
+		// The operator's ConfigMaps are usually outside the namespaces it watches, so they are
+		// read from the API server rather than the cache
+		read, err := readPolicies(r.apiReader, types.NamespacedName{Namespace: parts[0], Name: parts[1]})
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, read...)
+	}
+	for _, name := range instance.Spec.PolicyConfigMaps {
+		read, err := readPolicies(r.client, types.NamespacedName{Namespace: instance.Namespace, Name: name})
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, read...)
+	}
+	return policies, nil
+}
+
+// readPolicies reads the policies of a ConfigMap, in key order
+func readPolicies(reader client.Reader, name types.NamespacedName) ([]*policy, error) {
+	cm := &corev1.ConfigMap{}
+	if err := reader.Get(context.TODO(), name, cm); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var policies []*policy
+	for _, key := range keys {
+		data, err := yaml.ToJSON([]byte(cm.Data[key]))
+		if err != nil {
+			return nil, fmt.Errorf("reading policy %s of ConfigMap %s: %v", key, name, err)
+		}
+		p := &policy{}
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, fmt.Errorf("reading policy %s of ConfigMap %s: %v", key, name, err)
+		}
+		if p.Name == "" {
+			p.Name = key
+		}
+		switch p.Mode {
+		case "":
+			p.Mode = yagov1alpha1.PolicyEnforce
+		case yagov1alpha1.PolicyEnforce, yagov1alpha1.PolicyWarn:
+		default:
+			return nil, fmt.Errorf("policy %s of ConfigMap %s has an unknown mode %q", key, name, p.Mode)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// checkPolicies evaluates the policies against every manifest. It records the violations in the
+// status of instance and fails if any of them breaks an enforced policy.
+func (r *ReconcileYago) checkPolicies(instance *yagov1alpha1.Yago, manifests []*manifest) error {
+	policies, err := r.loadPolicies(instance)
+	if err != nil {
+		return err
+	}
+	var violations []yagov1alpha1.PolicyViolation
+	var enforced []string
+	for _, m := range manifests {
+		for _, p := range policies {
+			message, err := p.evaluate(m)
+			if err != nil {
+				return fmt.Errorf("evaluating policy %s: %v", p.Name, err)
+			}
+			if message == "" {
+				continue
+			}
+			violations = append(violations, yagov1alpha1.PolicyViolation{
+				Policy:  p.Name,
+				Mode:    p.Mode,
+				Path:    m.path,
+				Kind:    m.gvk.Kind,
+				Name:    m.obj.GetName(),
+				Message: message,
+			})
+			if p.Mode == yagov1alpha1.PolicyEnforce {
+				enforced = append(enforced, fmt.Sprintf("%s violates %s: %s", m.path, p.Name, message))
+			}
+		}
+	}
+	instance.Status.PolicyViolations = violations
+	if len(enforced) > 0 {
+		return fmt.Errorf("policy violations: %s", strings.Join(enforced, "; "))
+	}
+	return nil
+}
+
+// evaluate returns why m violates the policy, empty if it does not
+func (p *policy) evaluate(m *manifest) (string, error) {
+	if len(p.Kinds) > 0 {
+		matches, err := matchesKind(parseKindPatterns(strings.Join(p.Kinds, ",")), m.gvk.GroupKind())
+		if err != nil || !matches {
+			return "", err
+		}
+	}
+	for _, rule := range p.Rules {
+		reason, err := rule.evaluate(m.obj.Object)
+		if err != nil {
+			return "", err
+		}
+		if reason == "" {
+			continue
+		}
+		if p.Message != "" {
+			return p.Message, nil
+		}
+		return reason, nil
+	}
+	return "", nil
+}
+
+// evaluate returns why obj breaks the rule, empty if it does not
+func (rule *policyRule) evaluate(obj map[string]interface{}) (string, error) {
+	segments, err := parseJSONPath(rule.Path)
+	if err != nil {
+		return "", err
+	}
+	for _, fp := range expandJSONPath(obj, segments) {
+		value, ok := getField(obj, fp)
+		if !ok {
+			continue
+		}
+		location := fp.String()
+		if rule.Pattern != "" {
+			s, isString := value.(string)
+			if !isString || !globMatch(rule.Pattern, s) {
+				return fmt.Sprintf("%s is %v, which does not match %s", location, value, rule.Pattern), nil
+			}
+		}
+		for _, forbidden := range rule.Forbidden {
+			if fmt.Sprint(value) == fmt.Sprint(forbidden) {
+				return fmt.Sprintf("%s may not be %v", location, forbidden), nil
+			}
+		}
+		for _, required := range rule.Required {
+			if !strings.HasPrefix(required, ".") {
+				required = "." + required
+			}
+			subSegments, err := parseJSONPath(required)
+			if err != nil {
+				return "", err
+			}
+			found := false
+			for _, sub := range expandJSONPath(value, subSegments) {
+				if _, ok := getField(value, sub); ok {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Sprintf("%s has no %s", location, strings.TrimPrefix(required, ".")), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// globMatch tells whether s matches the glob pattern, where * matches any text and ? any character
+func globMatch(pattern, s string) bool {
+	p, t := []rune(pattern), []rune(s)
+	// star is the last * of the pattern seen so far, and mark where its match in s ends
+	star, mark := -1, 0
+	i, j := 0, 0
+	for j < len(t) {
+		switch {
+		case i < len(p) && p[i] == '*':
+			star, mark = i, j
+			i++
+		case i < len(p) && (p[i] == '?' || p[i] == t[j]):
+			i++
+			j++
+		case star >= 0:
+			// Let the last * match one more character and try again from there
+			mark++
+			i, j = star+1, mark
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
@@ -0,0 +1,252 @@
+package yago
+
+import (
+	"strings"
+	"testing"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{pattern: "", s: "", want: true},
+		{pattern: "", s: "a", want: false},
+		{pattern: "*", s: "", want: true},
+		{pattern: "*", s: "registry.example.com/app:1", want: true},
+		{pattern: "registry.example.com/*", s: "registry.example.com/team/app:1", want: true},
+		{pattern: "registry.example.com/*", s: "registry.example.org/app:1", want: false},
+		{pattern: "registry.example.com/*", s: "evil.com/registry.example.com/app", want: false},
+		{pattern: "*:latest", s: "app:latest", want: true},
+		{pattern: "*:latest", s: "app:latest-1", want: false},
+		{pattern: "*/app:*", s: "a/b/app:1", want: true},
+		{pattern: "a*b*c", s: "abbbc", want: true},
+		{pattern: "a*b*c", s: "acb", want: false},
+		{pattern: "app:?", s: "app:1", want: true},
+		{pattern: "app:?", s: "app:12", want: false},
+		{pattern: "app:?", s: "app:é", want: true},
+		{pattern: "**", s: "anything", want: true},
+		// Characters special to regular expressions are plain text
+		{pattern: "a.b", s: "axb", want: false},
+		{pattern: "a.b", s: "a.b", want: true},
+		{pattern: "[ab]", s: "a", want: false},
+		{pattern: "(a|b)+", s: "(a|b)+", want: true},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func deploymentWith(containers ...map[string]interface{}) map[string]interface{} {
+	var list []interface{}
+	for _, c := range containers {
+		list = append(list, c)
+	}
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"containers": list},
+			},
+		},
+	}
+}
+
+func TestPolicyRuleEvaluate(t *testing.T) {
+	app := map[string]interface{}{
+		"name":      "app",
+		"image":     "registry.example.com/app:1",
+		"resources": map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}},
+	}
+	sidecar := map[string]interface{}{
+		"name":            "sidecar",
+		"image":           "docker.io/sidecar:latest",
+		"securityContext": map[string]interface{}{"privileged": true},
+	}
+	tests := []struct {
+		name    string
+		rule    policyRule
+		obj     map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "pattern holds",
+			rule: policyRule{Path: ".spec.template.spec.containers[*].image", Pattern: "registry.example.com/*"},
+			obj:  deploymentWith(app),
+		},
+		{
+			name: "pattern broken by one field",
+			rule: policyRule{Path: ".spec.template.spec.containers[*].image", Pattern: "registry.example.com/*"},
+			obj:  deploymentWith(app, sidecar),
+			want: "/spec/template/spec/containers/1/image is docker.io/sidecar:latest, which does not match registry.example.com/*",
+		},
+		{
+			name: "pattern on a field that is not a string",
+			rule: policyRule{Path: ".spec.template.spec.containers[*].securityContext.privileged", Pattern: "*"},
+			obj:  deploymentWith(sidecar),
+			want: "/spec/template/spec/containers/0/securityContext/privileged is true, which does not match *",
+		},
+		{
+			name: "absent fields are not checked",
+			rule: policyRule{Path: ".spec.template.spec.containers[*].image", Pattern: "registry.example.com/*"},
+			obj:  map[string]interface{}{"data": map[string]interface{}{"a": "b"}},
+		},
+		{
+			name: "forbidden value",
+			rule: policyRule{Path: ".spec.template.spec.containers[*].securityContext.privileged", Forbidden: []interface{}{true}},
+			obj:  deploymentWith(app, sidecar),
+			want: "/spec/template/spec/containers/1/securityContext/privileged may not be true",
+		},
+		{
+			name: "allowed value",
+			rule: policyRule{Path: ".spec.template.spec.containers[*].securityContext.privileged", Forbidden: []interface{}{true}},
+			obj:  deploymentWith(map[string]interface{}{"name": "app", "securityContext": map[string]interface{}{"privileged": false}}),
+		},
+		{
+			name: "required subfield present",
+			rule: policyRule{Path: ".spec.template.spec.containers[?(@.name=='app')]", Required: []string{"resources.limits.memory"}},
+			obj:  deploymentWith(app, sidecar),
+		},
+		{
+			name: "required subfield missing",
+			rule: policyRule{Path: ".spec.template.spec.containers[*]", Required: []string{".resources.limits"}},
+			obj:  deploymentWith(app, sidecar),
+			want: "/spec/template/spec/containers/1 has no resources.limits",
+		},
+		{
+			name: "filter selects the checked elements",
+			rule: policyRule{Path: ".spec.template.spec.containers[?(@.name=='sidecar')].image", Pattern: "docker.io/*"},
+			obj:  deploymentWith(app, sidecar),
+		},
+		{
+			name:    "invalid path",
+			rule:    policyRule{Path: "spec", Pattern: "*"},
+			obj:     deploymentWith(app),
+			wantErr: true,
+		},
+		{
+			name:    "invalid required path",
+			rule:    policyRule{Path: ".spec", Required: []string{"template["}},
+			obj:     deploymentWith(app),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.rule.evaluate(tt.obj)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: evaluate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: evaluate() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPolicies(t *testing.T) {
+	operatorPolicies := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "guardrails", Namespace: "yago-system"},
+		Data: map[string]string{
+			"no-privileged": `
+kinds: [Deployment.apps]
+rules:
+- path: .spec.template.spec.containers[*].securityContext.privileged
+  forbidden: [true]
+`,
+		},
+	}
+	teamPolicies := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-policies", Namespace: "team"},
+		Data: map[string]string{
+			"registry": `
+mode: warn
+kinds: [Deployment.apps]
+message: images must come from registry.example.com
+rules:
+- path: .spec.template.spec.containers[*].image
+  pattern: registry.example.com/*
+`,
+			"everything": `
+name: named
+rules:
+- path: .metadata.labels.team
+  forbidden: [other]
+`,
+		},
+	}
+	// Only the operator's ConfigMaps are read from the API server, the cache holds the team's
+	r := &ReconcileYago{
+		client:    fake.NewFakeClientWithScheme(testScheme(t), teamPolicies),
+		apiReader: fake.NewFakeClientWithScheme(testScheme(t), operatorPolicies),
+	}
+	defer func(value string) { *policyConfigMaps = value }(*policyConfigMaps)
+
+	deployment := &unstructured.Unstructured{Object: deploymentWith(map[string]interface{}{
+		"name":            "app",
+		"image":           "docker.io/app:1",
+		"securityContext": map[string]interface{}{"privileged": true},
+	})}
+	deployment.SetName("app")
+	deployment.SetLabels(map[string]string{"team": "other"})
+	manifests := []*manifest{{
+		path: "deployment.yaml",
+		obj:  deployment,
+		gvk:  schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+	}}
+
+	tests := []struct {
+		name           string
+		operator       string
+		teamConfigMaps []string
+		wantPolicies   []string
+		wantErr        string
+	}{
+		{name: "no policies"},
+		{
+			name:           "team policies only",
+			teamConfigMaps: []string{"team-policies"},
+			wantPolicies:   []string{"named", "registry"},
+			wantErr:        "deployment.yaml violates named",
+		},
+		{
+			name:         "operator policies apply without team policies",
+			operator:     "yago-system/guardrails",
+			wantPolicies: []string{"no-privileged"},
+			wantErr:      "deployment.yaml violates no-privileged",
+		},
+		{
+			name:           "operator policies come first",
+			operator:       " yago-system/guardrails, ",
+			teamConfigMaps: []string{"team-policies"},
+			wantPolicies:   []string{"no-privileged", "named", "registry"},
+			wantErr:        "deployment.yaml violates no-privileged",
+		},
+		{name: "invalid operator ConfigMap", operator: "guardrails", wantErr: "expected namespace/name"},
+		{name: "missing operator ConfigMap", operator: "yago-system/missing", wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		*policyConfigMaps = tt.operator
+		instance := &yagov1alpha1.Yago{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team"}}
+		instance.Spec.PolicyConfigMaps = tt.teamConfigMaps
+		err := r.checkPolicies(instance, manifests)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: checkPolicies() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		var got []string
+		for _, v := range instance.Status.PolicyViolations {
+			got = append(got, v.Policy)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.wantPolicies, ",") {
+			t.Errorf("%s: violated policies = %v, want %v", tt.name, got, tt.wantPolicies)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// driftCheckInterval is how often the objects in remote clusters are synced again. They are not
+// watched, so this is how drift gets noticed.
+const driftCheckInterval = 3 * time.Minute
+
+// defaultKubeConfigKey is the key of the kubeconfig in its Secret if the Yago does not set one
+const defaultKubeConfigKey = "kubeconfig"
+
+// remoteCluster is a cluster reached through the kubeconfig of a Secret
+type remoteCluster struct {
+	// key identifies the Yago, and the Secret and version of it the cluster was built from
+	key    string
+	config *rest.Config
+	mapper meta.RESTMapper
+}
+
+// remoteCluster returns the cluster of the kubeconfig Secret of instance, building it again
+// whenever the Secret changes. Each Yago has a cluster of its own, which is evicted when the Yago
+// is gone or stops naming a kubeconfig.
+func (r *ReconcileYago) remoteCluster(instance *yagov1alpha1.Yago) (*remoteCluster, error) {
+	ref := instance.Spec.KubeConfig.SecretRef
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, err
+	}
+	name := types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}.String()
+	key := fmt.Sprintf("%s:%s@%s", name, ref.Name, secret.ResourceVersion)
+
+	c := r.clients
+	c.lock.Lock()
+	cluster, ok := c.clusters[name]
+	c.lock.Unlock()
+	if ok && cluster.key == key {
+		return cluster, nil
+	}
+
+	dataKey := ref.Key
+	if dataKey == "" {
+		dataKey = defaultKubeConfigKey
+	}
+	data, ok := secret.Data[dataKey]
+	if !ok {
+		return nil, fmt.Errorf("no key %s in Secret %s", dataKey, ref.Name)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig of Secret %s: %v", ref.Name, err)
+	}
+	mapper, err := apiutil.NewDynamicRESTMapper(config)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the cluster of Secret %s: %v", ref.Name, err)
+	}
+	if cluster != nil {
+		c.forget(cluster)
+	}
+	cluster = &remoteCluster{key: key, config: config, mapper: mapper}
+	c.lock.Lock()
+	c.clusters[name] = cluster
+	c.lock.Unlock()
+	return cluster, nil
+}
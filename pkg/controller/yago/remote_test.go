@@ -0,0 +1,366 @@
+package yago
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aerdei/yago/pkg/apis"
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFinalizeWithoutKubeConfigSecret(t *testing.T) {
+	now := metav1.Now()
+	instance := &yagov1alpha1.Yago{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "edge",
+			Namespace:         "default",
+			Finalizers:        []string{yagov1alpha1.Finalizer},
+			DeletionTimestamp: &now,
+		},
+		Spec: yagov1alpha1.YagoSpec{
+			KubeConfig: &yagov1alpha1.KubeConfigSpec{SecretRef: yagov1alpha1.KubeConfigSecretRef{Name: "edge-kubeconfig"}},
+		},
+	}
+	s := testScheme(t)
+	cl := fake.NewFakeClientWithScheme(s, instance)
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileYago{
+		client:    cl,
+		objects:   cl,
+		scheme:    s,
+		recorder:  recorder,
+		clients:   newClientCache(&rest.Config{}, s, nil),
+		checkouts: newCheckoutCache(),
+	}
+	// A cluster built before the Secret went away
+	cluster := &remoteCluster{key: "default/edge:edge-kubeconfig@1", config: &rest.Config{}}
+	r.clients.clusters["default/edge"] = cluster
+	r.clients.clients[cluster.key+"|"] = cl
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "edge"}})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	found := &yagov1alpha1.Yago{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "edge"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if hasFinalizer(found) {
+		t.Errorf("finalizer was kept, the Yago would stay Terminating")
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "KubeConfigMissing") {
+			t.Errorf("event = %q, want KubeConfigMissing", event)
+		}
+	default:
+		t.Errorf("no event recorded")
+	}
+	if len(r.clients.clusters) != 0 || len(r.clients.clients) != 0 {
+		t.Errorf("clusters %v and clients %v were not evicted", r.clients.clusters, r.clients.clients)
+	}
+}
+
+func TestSuspendWithoutKubeConfigSecret(t *testing.T) {
+	instance := &yagov1alpha1.Yago{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "default"},
+		Spec: yagov1alpha1.YagoSpec{
+			Suspend:    true,
+			KubeConfig: &yagov1alpha1.KubeConfigSpec{SecretRef: yagov1alpha1.KubeConfigSecretRef{Name: "edge-kubeconfig"}},
+		},
+	}
+	s := testScheme(t)
+	cl := fake.NewFakeClientWithScheme(s, instance)
+	r := &ReconcileYago{
+		client:    cl,
+		objects:   cl,
+		scheme:    s,
+		recorder:  record.NewFakeRecorder(10),
+		clients:   newClientCache(&rest.Config{}, s, nil),
+		checkouts: newCheckoutCache(),
+	}
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "edge"}})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	found := &yagov1alpha1.Yago{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "edge"}, found); err != nil {
+		t.Fatal(err)
+	}
+	if !found.Status.IsConditionTrue(yagov1alpha1.ConditionSuspended) {
+		t.Errorf("Suspended condition = %v, want True", found.Status.GetCondition(yagov1alpha1.ConditionSuspended))
+	}
+}
+
+func TestClientCacheEvict(t *testing.T) {
+	c := newClientCache(&rest.Config{}, runtime.NewScheme(), nil)
+	edge := &remoteCluster{key: "default/edge:kubeconfig@1"}
+	other := &remoteCluster{key: "default/other:kubeconfig@1"}
+	c.clusters["default/edge"] = edge
+	c.clusters["default/other"] = other
+	c.clients[edge.key+"|"] = nil
+	c.clients[edge.key+"|system:serviceaccount:default:deployer"] = nil
+	c.clients[other.key+"|"] = nil
+	c.clients["|"] = nil
+
+	c.evict("default/edge")
+	c.evict("default/missing")
+	if _, ok := c.clusters["default/edge"]; ok {
+		t.Errorf("cluster of default/edge was not evicted")
+	}
+	if len(c.clusters) != 1 {
+		t.Errorf("clusters = %v, want only default/other", c.clusters)
+	}
+	if len(c.clients) != 2 {
+		t.Errorf("clients = %v, want the ones of default/other and the local cluster", c.clients)
+	}
+}
+
+// startEnv starts an API server, skipping the test if the envtest binaries are not installed
+func startEnv(t *testing.T, env *envtest.Environment) *rest.Config {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+	config, err := env.Start()
+	if err != nil {
+		t.Fatalf("starting API server: %v", err)
+	}
+	return config
+}
+
+// kubeConfigOf writes a kubeconfig reaching the API server of config
+func kubeConfigOf(t *testing.T, config *rest.Config) []byte {
+	kubeConfig := clientcmdapi.NewConfig()
+	kubeConfig.Clusters["remote"] = &clientcmdapi.Cluster{
+		Server:                   config.Host,
+		CertificateAuthorityData: config.CAData,
+	}
+	kubeConfig.AuthInfos["remote"] = &clientcmdapi.AuthInfo{
+		ClientCertificateData: config.CertData,
+		ClientKeyData:         config.KeyData,
+		Token:                 config.BearerToken,
+	}
+	kubeConfig.Contexts["remote"] = &clientcmdapi.Context{Cluster: "remote", AuthInfo: "remote"}
+	kubeConfig.CurrentContext = "remote"
+	data, err := clientcmd.Write(*kubeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testRepository creates a repository with a commit of the files on master
+func testRepository(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "yago-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = w.Commit("Add manifests", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRemoteCluster(t *testing.T) {
+	local := &envtest.Environment{CRDDirectoryPaths: []string{filepath.Join("..", "..", "..", "deploy", "crds")}}
+	localConfig := startEnv(t, local)
+	defer local.Stop()
+	remote := &envtest.Environment{}
+	remoteConfig := startEnv(t, remote)
+	defer remote.Stop()
+
+	s := testScheme(t)
+	mapper, err := apiutil.NewDynamicRESTMapper(localConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localClient, err := client.New(localConfig, client.Options{Scheme: s, Mapper: mapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteClient, err := client.New(remoteConfig, client.Options{Scheme: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ReconcileYago{
+		client:    localClient,
+		objects:   localClient,
+		scheme:    s,
+		mapper:    mapper,
+		recorder:  record.NewFakeRecorder(100),
+		clients:   newClientCache(localConfig, s, mapper),
+		schemas:   &schemaCache{schemas: map[string]*clusterSchema{}},
+		checkouts: newCheckoutCache(),
+	}
+
+	repo := testRepository(t, map[string]string{"configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: edge-settings
+data:
+  region: north
+`})
+	defer os.RemoveAll(repo)
+
+	ctx := context.TODO()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge-kubeconfig", Namespace: "default"},
+		Data:       map[string][]byte{defaultKubeConfigKey: kubeConfigOf(t, remoteConfig)},
+	}
+	if err := localClient.Create(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	instance := &yagov1alpha1.Yago{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "default"},
+		Spec: yagov1alpha1.YagoSpec{
+			Repository:      repo,
+			BranchReference: "master",
+			KubeConfig:      &yagov1alpha1.KubeConfigSpec{SecretRef: yagov1alpha1.KubeConfigSecretRef{Name: secret.Name}},
+		},
+	}
+	if err := localClient.Create(ctx, instance); err != nil {
+		t.Fatal(err)
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "edge"}}
+	configMap := types.NamespacedName{Namespace: "default", Name: "edge-settings"}
+
+	// The tree is applied to the remote cluster only
+	applied := &corev1.ConfigMap{}
+	for i := 0; ; i++ {
+		if _, err := r.Reconcile(request); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		err := remoteClient.Get(ctx, configMap, applied)
+		if err == nil {
+			break
+		}
+		if !errors.IsNotFound(err) || i == 10 {
+			t.Fatalf("ConfigMap was not applied to the remote cluster: %v", err)
+		}
+	}
+	if applied.Data["region"] != "north" {
+		t.Errorf("remote ConfigMap data = %v", applied.Data)
+	}
+	if err := localClient.Get(ctx, configMap, &corev1.ConfigMap{}); !errors.IsNotFound(err) {
+		t.Errorf("ConfigMap was applied to the local cluster: %v", err)
+	}
+
+	// Drift in the remote cluster is reverted by the periodic sync
+	applied.Data["region"] = "south"
+	if err := remoteClient.Update(ctx, applied); err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Reconcile(request)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter == 0 || result.RequeueAfter > driftCheckInterval {
+		t.Errorf("RequeueAfter = %v, want a drift check within %v", result.RequeueAfter, driftCheckInterval)
+	}
+	if err := remoteClient.Get(ctx, configMap, applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied.Data["region"] != "north" {
+		t.Errorf("drift was not reverted, remote ConfigMap data = %v", applied.Data)
+	}
+
+	// Objects in the remote cluster cannot be owned, so they are deleted along with the Yago
+	if err := localClient.Delete(ctx, instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	deleted := &corev1.ConfigMap{}
+	if err := remoteClient.Get(ctx, configMap, deleted); err == nil && deleted.GetDeletionTimestamp() == nil {
+		t.Errorf("remote ConfigMap was not deleted")
+	} else if err != nil && !errors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	if err := localClient.Get(ctx, request.NamespacedName, &yagov1alpha1.Yago{}); !errors.IsNotFound(err) {
+		t.Errorf("Yago was not finalized: %v", err)
+	}
+
+	// Without its Secret a deleted Yago is let go instead of staying Terminating
+	instance = &yagov1alpha1.Yago{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "default"},
+		Spec: yagov1alpha1.YagoSpec{
+			Repository:      repo,
+			BranchReference: "master",
+			KubeConfig:      &yagov1alpha1.KubeConfigSpec{SecretRef: yagov1alpha1.KubeConfigSecretRef{Name: secret.Name}},
+		},
+	}
+	if err := localClient.Create(ctx, instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := localClient.Delete(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := localClient.Delete(ctx, instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := localClient.Get(ctx, request.NamespacedName, &yagov1alpha1.Yago{}); !errors.IsNotFound(err) {
+		t.Errorf("Yago without its kubeconfig Secret was not finalized: %v", err)
+	}
+	if len(r.clients.clusters) != 0 {
+		t.Errorf("clusters = %v, want none once the Yago is gone", r.clients.clusters)
+	}
+}
@@ -0,0 +1,54 @@
+package yago
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"github.com/aerdei/yago/pkg/controller/gitutils"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// commitHash extracts the hash from a commit recorded in the status
+func commitHash(commit string) plumbing.Hash {
+	return plumbing.NewHash(strings.Fields(commit)[0])
+}
+
+// gitDepth is the number of commits fetched from the tip of the branch, 0 for the whole history
+func gitDepth(instance *yagov1alpha1.Yago) int {
+	if instance.Spec.Git == nil {
+		return 0
+	}
+	return int(instance.Spec.Git.Depth)
+}
+
+// fetchLastHealthy returns the reference, commit and tree of the last healthy commit, on the branch of head
+func fetchLastHealthy(
+	instance *yagov1alpha1.Yago,
+	head *plumbing.Reference,
+	trustedKeys []string) (*plumbing.Reference, *object.Commit, *object.Tree, error) {
+
+	hash := commitHash(instance.Status.LastHealthyCommit)
+	commit, tree, err := gitutils.HandleCommit(instance.Spec.Repository, instance.Spec.BranchReference, gitDepth(instance), hash, trustedKeys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return plumbing.NewHashReference(head.Name(), hash), commit, tree, nil
+}
+
+// rollbackDue tells whether the current commit has been failing or unhealthy for longer than the
+// rollback timeout and there is a healthy commit to roll back to
+func rollbackDue(instance *yagov1alpha1.Yago, commit string, now time.Time) (bool, error) {
+	rollback := instance.Spec.Rollback
+	if rollback == nil || instance.Status.LastHealthyCommit == "" ||
+		instance.Status.LastHealthyCommit == commit || instance.Status.AppliedAt == nil {
+		return false, nil
+	}
+	timeout, err := time.ParseDuration(rollback.Timeout)
+	if err != nil {
+		return false, fmt.Errorf("invalid rollback timeout %q: %v", rollback.Timeout, err)
+	}
+	return now.Sub(instance.Status.AppliedAt.Time) > timeout, nil
+}
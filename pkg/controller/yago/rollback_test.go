@@ -0,0 +1,49 @@
+package yago
+
+import (
+	"testing"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRollbackDue(t *testing.T) {
+	now := time.Date(2020, time.January, 6, 12, 0, 0, 0, time.UTC)
+	current, healthy := "2222222222222222222222222222222222222222 refs/heads/master", "1111111111111111111111111111111111111111 refs/heads/master"
+	tests := []struct {
+		name        string
+		timeout     string
+		lastHealthy string
+		appliedAgo  time.Duration
+		want        bool
+		wantErr     bool
+	}{
+		{name: "rollback disabled", lastHealthy: healthy, appliedAgo: time.Hour},
+		{name: "timeout not reached", timeout: "10m", lastHealthy: healthy, appliedAgo: 5 * time.Minute},
+		{name: "timeout exceeded", timeout: "10m", lastHealthy: healthy, appliedAgo: 11 * time.Minute, want: true},
+		{name: "no healthy commit to roll back to", timeout: "10m", appliedAgo: time.Hour},
+		{name: "current commit is the healthy one", timeout: "10m", lastHealthy: current, appliedAgo: time.Hour},
+		{name: "commit not applied yet", timeout: "10m", lastHealthy: healthy},
+		{name: "invalid timeout", timeout: "ten minutes", lastHealthy: healthy, appliedAgo: time.Hour, wantErr: true},
+	}
+	for _, tt := range tests {
+		instance := &yagov1alpha1.Yago{}
+		if tt.timeout != "" {
+			instance.Spec.Rollback = &yagov1alpha1.RollbackSpec{Timeout: tt.timeout}
+		}
+		instance.Status.LastHealthyCommit = tt.lastHealthy
+		if tt.appliedAgo != 0 {
+			appliedAt := metav1.NewTime(now.Add(-tt.appliedAgo))
+			instance.Status.AppliedAt = &appliedAt
+		}
+		got, err := rollbackDue(instance, current, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: rollbackDue() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: rollbackDue() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package yago
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+	"k8s.io/kubectl/pkg/util/openapi"
+)
+
+// schemaTTL is how long the OpenAPI schema of a cluster is used before it is fetched again, so
+// that newly installed CRDs get validated too
+const schemaTTL = 10 * time.Minute
+
+// schemaCache holds the OpenAPI schema of each cluster, keyed like the clusters of clientCache
+type schemaCache struct {
+	lock    sync.Mutex
+	schemas map[string]*clusterSchema
+}
+
+type clusterSchema struct {
+	resources openapi.Resources
+	fetchedAt time.Time
+}
+
+// openAPIResources returns the OpenAPI schema of the cluster the managed objects are applied to
+func (r *ReconcileYago) openAPIResources() (openapi.Resources, error) {
+	config, key := r.clients.config, ""
+	if r.cluster != nil {
+		config, key = r.cluster.config, r.cluster.key
+	}
+	c := r.schemas
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if s, ok := c.schemas[key]; ok && time.Since(s.fetchedAt) < schemaTTL {
+		return s.resources, nil
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := dc.OpenAPISchema()
+	if err != nil {
+		return nil, fmt.Errorf("fetching the OpenAPI schema: %v", err)
+	}
+	resources, err := openapi.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, err
+	}
+	c.schemas[key] = &clusterSchema{resources: resources, fetchedAt: time.Now()}
+	return resources, nil
+}
+
+// validateManifests validates every manifest against the OpenAPI schema of its kind, so that
+// invalid objects fail the whole commit before anything is written. Kinds the cluster publishes
+// no schema for, like those of CRDs in the same commit, are not validated.
+func (r *ReconcileYago) validateManifests(manifests []*manifest) error {
+	resources, err := r.openAPIResources()
+	if err != nil {
+		return err
+	}
+	var invalid []string
+	for _, m := range manifests {
+		s := resources.LookupResource(m.gvk)
+		if s == nil {
+			continue
+		}
+		for _, err := range validation.ValidateModel(m.obj.Object, s, m.gvk.Kind) {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", m.path, err))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid manifests: %s", strings.Join(invalid, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package yago
+
+import (
+	"flag"
+	"fmt"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// allowClusterScoped lets Yagos manage cluster-scoped objects. These are shared by every
+// namespace, so the operator has to opt in.
+var allowClusterScoped = flag.Bool("allow-cluster-scoped", false,
+	"Allow Yagos to manage cluster-scoped objects such as Namespaces, CRDs and ClusterRoles")
+
+// isNamespaced tells whether objects of gvk live in a namespace
+func (r *ReconcileYago) isNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+	return mapping.Scope.Name() != meta.RESTScopeNameRoot, nil
+}
+
+// targetNamespace returns the namespace a namespaced object is applied in: the namespace of its
+// manifest if it is allowed, the target namespace of the instance otherwise
+func targetNamespace(instance *yagov1alpha1.Yago, requested string) string {
+	for _, allowed := range instance.Spec.AllowedNamespaces {
+		if requested != "" && requested == allowed {
+			return requested
+		}
+	}
+	if instance.Spec.TargetNamespace != "" {
+		return instance.Spec.TargetNamespace
+	}
+	return instance.Namespace
+}
+
+// resolveNamespace sets the namespace of a manifest according to the scope of its kind, refusing
+// cluster-scoped kinds unless they are allowed
+func (r *ReconcileYago) resolveNamespace(instance *yagov1alpha1.Yago, m *manifest) error {
+	namespaced, err := r.isNamespaced(m.gvk)
+	if err != nil {
+		return err
+	}
+	if !namespaced {
+		if !*allowClusterScoped {
+			return fmt.Errorf("%s is a cluster-scoped %s, which the operator does not allow", m.path, m.gvk.Kind)
+		}
+		m.namespace = ""
+		return nil
+	}
+	m.namespace = targetNamespace(instance, m.obj.GetNamespace())
+	return nil
+}
+
+// resolveNamespaces resolves the namespace of every manifest whose kind is already served, so
+// that disallowed cluster-scoped objects and unknown kinds fail the sync before anything is
+// applied. Kinds defined by CRDs of the same commit are resolved once they are served.
+func (r *ReconcileYago) resolveNamespaces(
+	instance *yagov1alpha1.Yago,
+	manifests []*manifest,
+	defined map[schema.GroupVersionKind]bool) error {
+
+	for _, m := range manifests {
+		err := r.resolveNamespace(instance, m)
+		if meta.IsNoMatchError(err) {
+			if defined[m.gvk] {
+				continue
+			}
+			return fmt.Errorf("%s: %v", m.path, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inventoryNamespace returns the namespace of an object of the inventory. Cluster-scoped objects
+// and objects recorded before namespaces were part of the inventory have none, the scope of
+// their kind tells them apart. The latter were always applied in the namespace of the instance.
+func (r *ReconcileYago) inventoryNamespace(
+	instance *yagov1alpha1.Yago,
+	o yagov1alpha1.ManagedObject,
+	gvk schema.GroupVersionKind) (string, error) {
+
+	if o.Namespace != "" {
+		return o.Namespace, nil
+	}
+	namespaced, err := r.isNamespaced(gvk)
+	if err != nil || !namespaced {
+		return "", err
+	}
+	return instance.Namespace, nil
+}
+
+// managedNamespaces are the namespaces instance applies objects or runs hooks in
+func managedNamespaces(instance *yagov1alpha1.Yago) []string {
+	namespaces := []string{hookNamespace(instance), targetNamespace(instance, "")}
+	namespaces = append(namespaces, instance.Spec.AllowedNamespaces...)
+	seen := map[string]bool{}
+	unique := namespaces[:0]
+	for _, ns := range namespaces {
+		if !seen[ns] {
+			seen[ns] = true
+			unique = append(unique, ns)
+		}
+	}
+	return unique
+}
+
+// ownable tells whether instance can be the owner of an object in namespace. Owner references
+// cannot point to another namespace or cluster, such objects are only tracked by label.
+func ownable(instance *yagov1alpha1.Yago, namespace string) bool {
+	return instance.Spec.KubeConfig == nil && namespace == instance.Namespace
+}
+
+// hookNamespace is the namespace hooks run in: the namespace of the Yago, or the target namespace
+// in a remote cluster
+func hookNamespace(instance *yagov1alpha1.Yago) string {
+	if instance.Spec.KubeConfig != nil {
+		return targetNamespace(instance, "")
+	}
+	return instance.Namespace
+}
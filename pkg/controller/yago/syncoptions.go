@@ -0,0 +1,172 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	serviceGroupKind     = schema.GroupKind{Kind: "Service"}
+	pvcGroupKind         = schema.GroupKind{Kind: "PersistentVolumeClaim"}
+	statefulSetGroupKind = schema.GroupKind{Group: "apps", Kind: "StatefulSet"}
+)
+
+// syncOptions change how the object of a manifest is updated
+type syncOptions struct {
+	// replace replaces the object instead of merge patching its spec
+	replace bool
+	// recreate is true or never, empty to follow forceUpdate
+	recreate string
+}
+
+// parseSyncOptions parses the value of the sync options annotation
+func parseSyncOptions(value string) (syncOptions, error) {
+	var options syncOptions
+	for _, option := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(option), "=", 2)
+		if len(kv) != 2 {
+			return options, fmt.Errorf("invalid sync option %q", option)
+		}
+		switch kv[0] {
+		case yagov1alpha1.SyncOptionReplace:
+			replace, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return options, fmt.Errorf("invalid sync option %q", option)
+			}
+			options.replace = replace
+		case yagov1alpha1.SyncOptionRecreate:
+			if kv[1] != "true" && kv[1] != "never" {
+				return options, fmt.Errorf("invalid sync option %q", option)
+			}
+			options.recreate = kv[1]
+		default:
+			return options, fmt.Errorf("unknown sync option %q", option)
+		}
+	}
+	return options, nil
+}
+
+// recreateAllowed tells whether an object whose immutable fields changed may be recreated
+func (o syncOptions) recreateAllowed(forceUpdate bool) bool {
+	if o.recreate == "" {
+		return forceUpdate
+	}
+	return o.recreate == "true"
+}
+
+// immutableFieldsChanged tells whether an update was rejected because it changes fields that
+// cannot be updated, going by the causes the API server reported
+func immutableFieldsChanged(err error) bool {
+	status, ok := err.(errors.APIStatus)
+	if !ok || !errors.IsInvalid(err) || status.Status().Details == nil {
+		return false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		switch string(cause.Type) {
+		case string(field.ErrorTypeInvalid):
+			if strings.HasSuffix(cause.Message, validation.FieldImmutableErrorMsg) {
+				return true
+			}
+		case string(field.ErrorTypeForbidden):
+			// StatefulSets and PVCs forbid updating their spec beyond a few fields
+			if cause.Field == "spec" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serverPopulatedPaths returns the fields the API server fills in when the manifest leaves them
+// out. They keep their live values, as they would otherwise show up as drift or, when replacing,
+// be rejected as changes to immutable fields.
+func serverPopulatedPaths(m *manifest, live *unstructured.Unstructured) []fieldPath {
+	var candidates []fieldPath
+	switch m.gvk.GroupKind() {
+	case serviceGroupKind:
+		candidates = append(candidates,
+			pathOf("spec", "clusterIP"),
+			pathOf("spec", "healthCheckNodePort"))
+		ports, _, _ := unstructured.NestedSlice(m.obj.Object, "spec", "ports")
+		for i := range ports {
+			candidates = append(candidates, pathOf("spec", "ports", strconv.Itoa(i), "nodePort"))
+		}
+	case jobGroupKind:
+		if manual, _, _ := unstructured.NestedBool(m.obj.Object, "spec", "manualSelector"); !manual {
+			candidates = append(candidates,
+				pathOf("spec", "selector"),
+				pathOf("spec", "template", "metadata", "labels", "controller-uid"),
+				pathOf("spec", "template", "metadata", "labels", "job-name"))
+		}
+	case pvcGroupKind:
+		candidates = append(candidates, pathOf("spec", "volumeName"))
+	}
+	var paths []fieldPath
+	for _, path := range candidates {
+		if _, ok := getField(m.obj.Object, path); ok {
+			continue
+		}
+		if _, ok := getField(live.Object, path); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// replaceObject replaces a live object with the desired one, keeping the metadata that is not
+// part of the manifest
+func (r *ReconcileYago) replaceObject(
+	instance *yagov1alpha1.Yago,
+	desired *unstructured.Unstructured,
+	found *unstructured.Unstructured) error {
+
+	replacement := desired.DeepCopy()
+	replacement.SetResourceVersion(found.GetResourceVersion())
+	replacement.SetOwnerReferences(found.GetOwnerReferences())
+	replacement.SetFinalizers(found.GetFinalizers())
+	if found.GetLabels()[yagov1alpha1.InstanceLabel] == string(instance.GetUID()) {
+		setTracking(instance, replacement)
+	}
+	return r.objects.Update(context.TODO(), replacement)
+}
+
+// recreateObject deletes a live object and creates it again from its manifest. The pods of a
+// StatefulSet are orphaned and adopted by the new StatefulSet as long as its selector is unchanged,
+// the dependents of other objects are deleted along with them.
+func (r *ReconcileYago) recreateObject(
+	instance *yagov1alpha1.Yago,
+	m *manifest,
+	found *unstructured.Unstructured) error {
+
+	// Waiting for a dry-run deletion would never end, and the recreation itself is allowed
+	if r.dryRun {
+		return nil
+	}
+	propagation := metav1.DeletePropagationBackground
+	if m.gvk.GroupKind() == statefulSetGroupKind {
+		liveSelector, _, _ := unstructured.NestedFieldNoCopy(found.Object, "spec", "selector")
+		selector, _, _ := unstructured.NestedFieldNoCopy(m.obj.Object, "spec", "selector")
+		if cmp.Equal(liveSelector, selector) {
+			propagation = metav1.DeletePropagationOrphan
+		}
+	}
+	if err := r.deleteAndWait(found.DeepCopy(), propagation); err != nil {
+		return err
+	}
+	m.obj.SetOwnerReferences(found.GetOwnerReferences())
+	if found.GetLabels()[yagov1alpha1.InstanceLabel] == string(instance.GetUID()) {
+		setTracking(instance, m.obj)
+	}
+	return r.objects.Create(context.TODO(), m.obj)
+}
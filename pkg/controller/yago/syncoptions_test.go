@@ -0,0 +1,120 @@
+package yago
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestParseSyncOptions(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    syncOptions
+		wantErr bool
+	}{
+		{value: "Replace=true", want: syncOptions{replace: true}},
+		{value: "Replace=false", want: syncOptions{}},
+		{value: "Recreate=true", want: syncOptions{recreate: "true"}},
+		{value: "Recreate=never", want: syncOptions{recreate: "never"}},
+		{value: " Replace=true , Recreate=never ", want: syncOptions{replace: true, recreate: "never"}},
+		{value: "Replace", wantErr: true},
+		{value: "Replace=maybe", wantErr: true},
+		{value: "Recreate=false", wantErr: true},
+		{value: "Prune=false", wantErr: true},
+		{value: "Replace=true,", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSyncOptions(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSyncOptions(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("parseSyncOptions(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRecreateAllowed(t *testing.T) {
+	tests := []struct {
+		recreate    string
+		forceUpdate bool
+		want        bool
+	}{
+		{recreate: "", forceUpdate: false, want: false},
+		{recreate: "", forceUpdate: true, want: true},
+		{recreate: "true", forceUpdate: false, want: true},
+		{recreate: "true", forceUpdate: true, want: true},
+		{recreate: "never", forceUpdate: false, want: false},
+		{recreate: "never", forceUpdate: true, want: false},
+	}
+	for _, tt := range tests {
+		if got := (syncOptions{recreate: tt.recreate}).recreateAllowed(tt.forceUpdate); got != tt.want {
+			t.Errorf("recreateAllowed(%v) with Recreate=%q = %v, want %v", tt.forceUpdate, tt.recreate, got, tt.want)
+		}
+	}
+}
+
+func TestImmutableFieldsChanged(t *testing.T) {
+	// The errors are built the way the API server validates updates of these kinds
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "job template",
+			err: errors.NewInvalid(schema.GroupKind{Group: "batch", Kind: "Job"}, "migrate", field.ErrorList{
+				field.Invalid(field.NewPath("spec", "template"), "{...}", validation.FieldImmutableErrorMsg),
+			}),
+			want: true,
+		},
+		{
+			name: "service cluster IP",
+			err: errors.NewInvalid(schema.GroupKind{Kind: "Service"}, "web", field.ErrorList{
+				field.Invalid(field.NewPath("spec", "clusterIP"), "10.0.0.2", validation.FieldImmutableErrorMsg),
+			}),
+			want: true,
+		},
+		{
+			name: "stateful set spec",
+			err: errors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, "db", field.ErrorList{
+				field.Forbidden(field.NewPath("spec"),
+					"updates to statefulset spec for fields other than 'replicas', 'template', and 'updateStrategy' are forbidden"),
+			}),
+			want: true,
+		},
+		{
+			name: "immutable field among other causes",
+			err: errors.NewInvalid(schema.GroupKind{Kind: "Service"}, "web", field.ErrorList{
+				field.Required(field.NewPath("spec", "ports"), ""),
+				field.Invalid(field.NewPath("spec", "clusterIP"), "10.0.0.2", validation.FieldImmutableErrorMsg),
+			}),
+			want: true,
+		},
+		{
+			name: "invalid value",
+			err: errors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "web", field.ErrorList{
+				field.Invalid(field.NewPath("spec", "replicas"), -1, "must be greater than or equal to 0"),
+			}),
+		},
+		{
+			name: "forbidden field below spec",
+			err: errors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "web", field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "template", "spec", "containers").Index(0).Child("securityContext", "privileged"),
+					"disallowed by cluster policy"),
+			}),
+		},
+		{name: "conflict", err: errors.NewConflict(schema.GroupResource{Resource: "services"}, "web", fmt.Errorf("modified"))},
+		{name: "not an API error", err: fmt.Errorf("%s", validation.FieldImmutableErrorMsg)},
+	}
+	for _, tt := range tests {
+		if got := immutableFieldsChanged(tt.err); got != tt.want {
+			t.Errorf("%s: immutableFieldsChanged() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
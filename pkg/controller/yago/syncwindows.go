@@ -0,0 +1,80 @@
+package yago
+
+import (
+	"fmt"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"github.com/aerdei/yago/pkg/controller/cronutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// syncWindowState is the outcome of evaluating the sync windows of a Yago at a given time
+type syncWindowState struct {
+	// allowed tells whether new commits may be applied
+	allowed bool
+	// nextChange is the closest time any window opens or closes, zero if there is none
+	nextChange time.Time
+	// next is the closest upcoming window
+	next *yagov1alpha1.SyncWindowStatus
+}
+
+// evaluateSyncWindows checks whether new commits may be applied at now. Syncing is denied while
+// any deny window is active, and only allowed inside an active allow window if allow windows are set.
+func evaluateSyncWindows(windows []yagov1alpha1.SyncWindow, now time.Time) (*syncWindowState, error) {
+	state := &syncWindowState{allowed: true}
+	hasAllow, inAllow, inDeny := false, false, false
+	for _, w := range windows {
+		schedule, err := cronutils.Parse(w.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		duration, err := time.ParseDuration(w.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sync window duration %q: %v", w.Duration, err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid sync window duration %q: must be positive", w.Duration)
+		}
+		loc := time.UTC
+		if w.TimeZone != "" {
+			if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+				return nil, err
+			}
+		}
+		local := now.In(loc)
+		// The window is active if it started within the last duration
+		active := false
+		if start := schedule.Next(local.Add(-duration)); !start.IsZero() && !start.After(local) {
+			active = true
+			state.updateNextChange(start.Add(duration))
+		}
+		if start := schedule.Next(local); !start.IsZero() {
+			state.updateNextChange(start)
+			if state.next == nil || start.Before(state.next.Start.Time) {
+				state.next = &yagov1alpha1.SyncWindowStatus{
+					Kind:  w.Kind,
+					Start: metav1.NewTime(start),
+					End:   metav1.NewTime(start.Add(duration)),
+				}
+			}
+		}
+		switch w.Kind {
+		case yagov1alpha1.SyncWindowAllow:
+			hasAllow = true
+			inAllow = inAllow || active
+		case yagov1alpha1.SyncWindowDeny:
+			inDeny = inDeny || active
+		default:
+			return nil, fmt.Errorf("unknown sync window kind %q", w.Kind)
+		}
+	}
+	state.allowed = !inDeny && (!hasAllow || inAllow)
+	return state, nil
+}
+
+func (s *syncWindowState) updateNextChange(t time.Time) {
+	if s.nextChange.IsZero() || t.Before(s.nextChange) {
+		s.nextChange = t
+	}
+}
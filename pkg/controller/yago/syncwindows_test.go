@@ -0,0 +1,124 @@
+package yago
+
+import (
+	"testing"
+	"time"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+)
+
+func TestEvaluateSyncWindows(t *testing.T) {
+	// A Monday at noon
+	now := time.Date(2020, time.January, 6, 12, 0, 0, 0, time.UTC)
+	allow := func(schedule, duration, timeZone string) yagov1alpha1.SyncWindow {
+		return yagov1alpha1.SyncWindow{Kind: yagov1alpha1.SyncWindowAllow, Schedule: schedule, Duration: duration, TimeZone: timeZone}
+	}
+	deny := func(schedule, duration string) yagov1alpha1.SyncWindow {
+		return yagov1alpha1.SyncWindow{Kind: yagov1alpha1.SyncWindowDeny, Schedule: schedule, Duration: duration}
+	}
+	tests := []struct {
+		name           string
+		windows        []yagov1alpha1.SyncWindow
+		wantAllowed    bool
+		wantNextChange time.Time
+		wantNextStart  time.Time
+		wantErr        bool
+	}{
+		{
+			name:        "no windows",
+			wantAllowed: true,
+		},
+		{
+			name:           "inside allow window",
+			windows:        []yagov1alpha1.SyncWindow{allow("0 9 * * *", "8h", "")},
+			wantAllowed:    true,
+			wantNextChange: time.Date(2020, time.January, 6, 17, 0, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 7, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "outside allow window",
+			windows:        []yagov1alpha1.SyncWindow{allow("0 13 * * *", "1h", "")},
+			wantAllowed:    false,
+			wantNextChange: time.Date(2020, time.January, 6, 13, 0, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 6, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "inside one of two allow windows",
+			windows:        []yagov1alpha1.SyncWindow{allow("0 13 * * *", "1h", ""), allow("30 11 * * *", "1h", "")},
+			wantAllowed:    true,
+			wantNextChange: time.Date(2020, time.January, 6, 12, 30, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 6, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "outside deny window",
+			windows:        []yagov1alpha1.SyncWindow{deny("0 22 * * *", "2h")},
+			wantAllowed:    true,
+			wantNextChange: time.Date(2020, time.January, 6, 22, 0, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 6, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "deny window overrides allow window",
+			windows:        []yagov1alpha1.SyncWindow{allow("0 9 * * *", "8h", ""), deny("0 11 * * 1", "2h")},
+			wantAllowed:    false,
+			wantNextChange: time.Date(2020, time.January, 6, 13, 0, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 7, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "deny window on another day",
+			windows:        []yagov1alpha1.SyncWindow{allow("0 9 * * *", "8h", ""), deny("0 11 * * 2", "2h")},
+			wantAllowed:    true,
+			wantNextChange: time.Date(2020, time.January, 6, 17, 0, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 7, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// Noon in UTC is seven in the morning in New York
+			name:           "allow window in another time zone",
+			windows:        []yagov1alpha1.SyncWindow{allow("0 9 * * *", "1h", "America/New_York")},
+			wantAllowed:    false,
+			wantNextChange: time.Date(2020, time.January, 6, 14, 0, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 6, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			// Noon in UTC is one in the afternoon in Berlin
+			name:           "active window in another time zone",
+			windows:        []yagov1alpha1.SyncWindow{allow("0 13 * * *", "30m", "Europe/Berlin")},
+			wantAllowed:    true,
+			wantNextChange: time.Date(2020, time.January, 6, 12, 30, 0, 0, time.UTC),
+			wantNextStart:  time.Date(2020, time.January, 7, 12, 0, 0, 0, time.UTC),
+		},
+		{name: "zero duration", windows: []yagov1alpha1.SyncWindow{allow("0 9 * * *", "0s", "")}, wantErr: true},
+		{name: "negative duration", windows: []yagov1alpha1.SyncWindow{deny("0 9 * * *", "-1h")}, wantErr: true},
+		{name: "invalid duration", windows: []yagov1alpha1.SyncWindow{allow("0 9 * * *", "1 hour", "")}, wantErr: true},
+		{name: "invalid schedule", windows: []yagov1alpha1.SyncWindow{allow("0 9 * *", "1h", "")}, wantErr: true},
+		{name: "invalid time zone", windows: []yagov1alpha1.SyncWindow{allow("0 9 * * *", "1h", "Nowhere/Town")}, wantErr: true},
+		{
+			name:    "unknown kind",
+			windows: []yagov1alpha1.SyncWindow{{Kind: "maybe", Schedule: "0 9 * * *", Duration: "1h"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		state, err := evaluateSyncWindows(tt.windows, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: evaluateSyncWindows() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if state.allowed != tt.wantAllowed {
+			t.Errorf("%s: evaluateSyncWindows() allowed = %v, want %v", tt.name, state.allowed, tt.wantAllowed)
+		}
+		if !state.nextChange.Equal(tt.wantNextChange) {
+			t.Errorf("%s: evaluateSyncWindows() nextChange = %v, want %v", tt.name, state.nextChange, tt.wantNextChange)
+		}
+		switch {
+		case tt.wantNextStart.IsZero() && state.next != nil:
+			t.Errorf("%s: evaluateSyncWindows() next = %v, want none", tt.name, state.next.Start)
+		case !tt.wantNextStart.IsZero() && state.next == nil:
+			t.Errorf("%s: evaluateSyncWindows() next = none, want %v", tt.name, tt.wantNextStart)
+		case state.next != nil && !state.next.Start.Time.Equal(tt.wantNextStart):
+			t.Errorf("%s: evaluateSyncWindows() next = %v, want %v", tt.name, state.next.Start, tt.wantNextStart)
+		}
+	}
+}
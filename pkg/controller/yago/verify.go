@@ -0,0 +1,77 @@
+package yago
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
+	"github.com/aerdei/yago/pkg/controller/gitutils"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// trustedKeys returns the armored public keys of the verify Secret of instance, none if commits
+// are not verified
+func (r *ReconcileYago) trustedKeys(instance *yagov1alpha1.Yago) ([]string, error) {
+	if instance.Spec.Verify == nil {
+		return nil, nil
+	}
+	ref := instance.Spec.Verify.SecretRef
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: instance.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, err
+	}
+	if ref.Key != "" {
+		data, ok := secret.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("no key %s in Secret %s", ref.Key, ref.Name)
+		}
+		return []string{string(data)}, nil
+	}
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var trusted []string
+	for _, key := range keys {
+		trusted = append(trusted, string(secret.Data[key]))
+	}
+	if len(trusted) == 0 {
+		return nil, fmt.Errorf("no keys in Secret %s", ref.Name)
+	}
+	return trusted, nil
+}
+
+// setVerifiedCondition records that the fetched commit was accepted
+func setVerifiedCondition(instance *yagov1alpha1.Yago, commit string) {
+	if instance.Spec.Verify != nil {
+		instance.Status.SetCondition(yagov1alpha1.ConditionVerificationFailed, corev1.ConditionFalse,
+			"Verified", fmt.Sprintf("Commit %s is signed by a trusted key", commit))
+		return
+	}
+	if instance.Status.GetCondition(yagov1alpha1.ConditionVerificationFailed) != nil {
+		instance.Status.SetCondition(yagov1alpha1.ConditionVerificationFailed, corev1.ConditionFalse,
+			"VerificationDisabled", "")
+	}
+}
+
+// refuseCommit keeps an unverified commit from being applied, leaving the objects of the last
+// accepted commit as they are
+func (r *ReconcileYago) refuseCommit(
+	instance *yagov1alpha1.Yago,
+	verr *gitutils.VerificationError,
+	reqLogger logr.Logger) (reconcile.Result, error) {
+
+	reqLogger.Info("Refusing unverified commit", "Commit", verr.Hash.String())
+	r.recorder.Event(instance, corev1.EventTypeWarning, "VerificationFailed", verr.Error())
+	instance.Status.SetCondition(yagov1alpha1.ConditionVerificationFailed, corev1.ConditionTrue,
+		"UntrustedCommit", verr.Error())
+	if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, verr
+}
@@ -3,8 +3,7 @@ package yago
 import (
 	"context"
 	"encoding/json"
-	"io"
-	"strings"
+	"fmt"
 	"time"
 
 	yagov1alpha1 "github.com/aerdei/yago/pkg/apis/yago/v1alpha1"
@@ -13,16 +12,16 @@ import (
 	"github.com/google/go-cmp/cmp"
 	appsv1 "github.com/openshift/api/apps/v1"
 	buildv1 "github.com/openshift/api/build/v1"
-	"gopkg.in/src-d/go-git.v4/plumbing"
-	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubectl/pkg/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -51,7 +50,17 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileYago{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileYago{
+		client:    mgr.GetClient(),
+		apiReader: mgr.GetAPIReader(),
+		objects:   mgr.GetClient(),
+		scheme:    mgr.GetScheme(),
+		mapper:    mgr.GetRESTMapper(),
+		recorder:  mgr.GetEventRecorderFor("yago-controller"),
+		clients:   newClientCache(mgr.GetConfig(), mgr.GetScheme(), mgr.GetRESTMapper()),
+		schemas:   &schemaCache{schemas: map[string]*clusterSchema{}},
+		checkouts: newCheckoutCache(),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -68,8 +77,15 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		&handler.EnqueueRequestForObject{},
 		predicate.Funcs{
 			UpdateFunc: func(e event.UpdateEvent) bool {
-				// Ignore updates to CR that are not changing spec
+				// Ignore updates to CR that are not changing spec or requesting a sync
 				log.Info("Checking update event")
+				if e.MetaNew.GetDeletionTimestamp() != nil {
+					return true
+				}
+				if e.MetaOld.GetAnnotations()[yagov1alpha1.RequestedAtAnnotation] !=
+					e.MetaNew.GetAnnotations()[yagov1alpha1.RequestedAtAnnotation] {
+					return true
+				}
 				unOld, err := runtime.DefaultUnstructuredConverter.ToUnstructured(e.ObjectOld)
 				if err != nil {
 					return false
@@ -121,15 +137,26 @@ type ReconcileYago struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
-	scheme *runtime.Scheme
+	// apiReader reads from the apiserver, for objects outside the namespaces the cache holds
+	apiReader client.Reader
+	// objects reads and writes the managed objects, impersonating the ServiceAccount of the
+	// Yago being reconciled if it names one
+	objects  client.Client
+	scheme   *runtime.Scheme
+	mapper   meta.RESTMapper
+	recorder record.EventRecorder
+	clients  *clientCache
+	schemas  *schemaCache
+	// checkouts holds the commit each Yago fetched last
+	checkouts *checkoutCache
+	// cluster is the remote cluster of the Yago being reconciled, nil for the operator's cluster
+	cluster *remoteCluster
+	// dryRun is set while objects is a dryRunClient
+	dryRun bool
 }
 
-// variable to track last succesful reference
 var (
-	ref           *plumbing.Reference
-	files         *object.Tree
 	deserializer  = serializer.NewCodecFactory(scheme.Scheme).UniversalDeserializer()
-	currentBranch string
 	retryInterval = time.Second * 5
 	timeout       = time.Second * 60
 )
@@ -150,133 +177,414 @@ func (r *ReconcileYago) Reconcile(request reconcile.Request) (reconcile.Result,
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			r.clients.evict(request.NamespacedName.String())
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
-	if files == nil || instance.Spec.BranchReference != currentBranch {
-		reqLogger.Info("Cloning repo")
-		if instance.Spec.BranchReference == "" {
-			instance.Spec.BranchReference = "Master"
+	// The deletion policy is carried out before the Yago is gone
+	if instance.GetDeletionTimestamp() != nil {
+		scoped, err := r.forInstance(instance)
+		if err != nil && instance.Spec.KubeConfig != nil && errors.IsNotFound(err) {
+			// Without its kubeconfig the remote cluster cannot be reached, so there is nothing left to clean up
+			reqLogger.Info("Kubeconfig Secret is gone, leaving remote objects as they are")
+			r.recorder.Event(instance, corev1.EventTypeWarning, "KubeConfigMissing",
+				fmt.Sprintf("Secret %s is gone, objects in the remote cluster are left as they are", instance.Spec.KubeConfig.SecretRef.Name))
+			return reconcile.Result{}, r.removeFinalizer(instance)
 		}
-		ref, files, err = gitutils.HandleRepo(instance.Spec.Repository, instance.Spec.BranchReference)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
+		return reconcile.Result{}, scoped.finalize(instance, reqLogger)
 	}
-	filesIter := files.Files()
-	for {
-		f, err := filesIter.Next()
-		if err == io.EOF {
-			reqLogger.Info("End of list")
-			instance.Status.CurrentCommit = ref.String()
-			currentBranch = instance.Spec.BranchReference
-			if err := r.client.Status().Update(context.TODO(), instance); err != nil {
-				return reconcile.Result{}, err
-			}
+	if err := r.ensureFinalizer(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	// A suspended Yago leaves every managed object as it is, only the condition is kept up to date.
+	// Neither its Secrets nor its cluster are needed for that, so suspending works without them.
+	if instance.Spec.Suspend {
+		reqLogger.Info("Yago is suspended, skipping sync")
+		if instance.Status.IsConditionTrue(yagov1alpha1.ConditionSuspended) {
 			return reconcile.Result{}, nil
+		}
+		instance.Status.SetCondition(yagov1alpha1.ConditionSuspended, corev1.ConditionTrue,
+			"SuspendRequested", "Syncing is suspended by spec.suspend")
+		if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+	// Only a Yago that was suspended before reports being resumed
+	if instance.Status.IsConditionTrue(yagov1alpha1.ConditionSuspended) {
+		instance.Status.SetCondition(yagov1alpha1.ConditionSuspended, corev1.ConditionFalse, "Resumed", "Syncing is resumed")
+	}
+	scoped, err := r.forInstance(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// A changed requestedAt annotation forces a fresh fetch of the repository, see checkoutCache.get
+	requestedAt := checkoutRequest(instance)
+	if requestedAt != instance.Status.LastHandledRequest {
+		reqLogger.Info("Sync requested", "RequestedAt", requestedAt)
+	}
+	if instance.Spec.BranchReference == "" {
+		instance.Spec.BranchReference = "Master"
+	}
+	// The keys are read on every sync, so that a commit is verified again once they change
+	trustedKeys, err := r.trustedKeys(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// Sync windows need the latest commit to tell whether there is anything to defer
+	windows := instance.Spec.SyncWindows
+	var requeueAfter time.Duration
+	co := r.checkouts.get(instance, trustedKeys)
+	if co == nil || len(windows) > 0 {
+		reqLogger.Info("Cloning repo")
+		fetchedRef, fetchedCommit, fetchedFiles, err := gitutils.HandleRepo(instance.Spec.Repository, instance.Spec.BranchReference, gitDepth(instance), trustedKeys)
+		if verr, ok := err.(*gitutils.VerificationError); ok {
+			return r.refuseCommit(instance, verr, reqLogger)
 		} else if err != nil {
 			return reconcile.Result{}, err
 		}
-
-		cont, err := f.Contents()
-		if err != nil {
+		instance.Status.NextSyncWindow = nil
+		if len(windows) > 0 {
+			state, err := evaluateSyncWindows(windows, time.Now())
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			instance.Status.NextSyncWindow = state.next
+			if !state.nextChange.IsZero() {
+				requeueAfter = time.Until(state.nextChange)
+			}
+			if !state.allowed && fetchedRef.String() != instance.Status.CurrentCommit {
+				reqLogger.Info("Outside of sync windows, deferring commit", "Commit", fetchedRef.String())
+				instance.Status.PendingCommit = fetchedRef.String()
+				if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+					return reconcile.Result{}, err
+				}
+				return reconcile.Result{RequeueAfter: requeueAfter}, nil
+			}
+		}
+		if instance.Status.RejectedCommit != "" {
+			if fetchedRef.String() == instance.Status.RejectedCommit {
+				// Keep the rolled back commit away until a newer one arrives
+				reqLogger.Info("Head is rejected, applying last healthy commit", "Commit", instance.Status.LastHealthyCommit)
+				fetchedRef, fetchedCommit, fetchedFiles, err = fetchLastHealthy(instance, fetchedRef, trustedKeys)
+				if verr, ok := err.(*gitutils.VerificationError); ok {
+					return r.refuseCommit(instance, verr, reqLogger)
+				} else if err != nil {
+					return reconcile.Result{}, err
+				}
+			} else {
+				r.recorder.Eventf(instance, corev1.EventTypeNormal, "RejectionCleared",
+					"Commit %s replaces rejected commit %s", fetchedRef.String(), instance.Status.RejectedCommit)
+				instance.Status.RejectedCommit = ""
+			}
+		}
+		setVerifiedCondition(instance, fetchedRef.Hash().String())
+		co = r.checkouts.set(instance, trustedKeys, fetchedRef, fetchedCommit, fetchedFiles)
+	}
+	requested := requestedAt != instance.Status.LastHandledRequest
+	record := newSyncRecord(co.ref.String(), co.commit)
+	stats := &syncStats{}
+	result, err := scoped.syncCommit(instance, co, requestedAt, requeueAfter, stats, reqLogger)
+	recordSync(instance, record, stats, requested, err)
+	// A sync waiting for the cluster is resumed later rather than failed
+	if isWaiting(err) {
+		reqLogger.Info("Sync in progress", "Reason", err.Error())
+		instance.Status.SetCondition(yagov1alpha1.ConditionSynced, corev1.ConditionFalse, "SyncInProgress", err.Error())
+		if err := r.client.Status().Update(context.TODO(), instance); err != nil {
 			return reconcile.Result{}, err
 		}
+		return reconcile.Result{RequeueAfter: retryInterval}, nil
+	}
+	if err != nil {
+		instance.Status.SetCondition(yagov1alpha1.ConditionSynced, corev1.ConditionFalse, "SyncFailed", err.Error())
+		// The failed attempt is recorded in the history on a best effort basis
+		if updateErr := r.client.Status().Update(context.TODO(), instance); updateErr != nil {
+			reqLogger.Error(updateErr, "Failed to record sync attempt")
+		}
+		return result, err
+	}
+	instance.Status.LastHandledRequest = requestedAt
+	instance.Status.SetCondition(yagov1alpha1.ConditionSynced, corev1.ConditionTrue, "SyncSucceeded", "")
+	if err := r.client.Status().Update(context.TODO(), instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	return result, nil
+}
 
-		unst := &unstructured.Unstructured{}
+// syncCommit applies the fetched commit and runs its hooks, updating the status of the instance
+// without persisting it
+func (r *ReconcileYago) syncCommit(
+	instance *yagov1alpha1.Yago,
+	co *checkout,
+	request string,
+	requeueAfter time.Duration,
+	stats *syncStats,
+	reqLogger logr.Logger) (reconcile.Result, error) {
 
-		_, gvk, err := deserializer.Decode([]byte(cont), nil, unst)
-		if err != nil {
+	manifests, err := decodeManifests(co.tree)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := checkKinds(instance, manifests); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.checkPolicies(instance, manifests); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.validateManifests(manifests); err != nil {
+		return reconcile.Result{}, err
+	}
+	objects, hooks := splitHooks(manifests)
+	sortManifests(objects)
+	defined := crdKinds(objects)
+	if err := r.resolveNamespaces(instance, objects, defined); err != nil {
+		return reconcile.Result{}, err
+	}
+	commit := co.ref.String()
+	// Hooks run once per commit, or again when a sync is requested
+	pruneHookStatus(&instance.Status, commit, request)
+	// Atomic syncs only write anything once every object passed a dry run
+	if instance.Spec.Atomic {
+		if err := r.dryRunObjects(instance, objects, reqLogger); err != nil {
 			return reconcile.Result{}, err
 		}
+	}
+	// The rollback timeout runs from the first attempt to apply the commit, failed ones included
+	if commit != instance.Status.CurrentCommit || instance.Status.AppliedAt == nil {
+		now := metav1.Now()
+		instance.Status.AppliedAt = &now
+	}
+	instance.Status.CurrentCommit = commit
+	instance.Status.PendingCommit = ""
 
-		name, isNameFound, err := unstructured.NestedString(unst.UnstructuredContent(), "metadata", "name")
-		if !isNameFound {
-			return reconcile.Result{}, err
+	requeueAfter, err = r.applyCommit(instance, objects, hooks, request, requeueAfter, stats, reqLogger)
+	// Commits that keep failing, or never get healthy, are rolled back just like unhealthy ones
+	rollback, rollbackErr := rollbackDue(instance, commit, time.Now())
+	if rollbackErr != nil {
+		return reconcile.Result{}, rollbackErr
+	}
+	if rollback {
+		problem := "it is not healthy"
+		if err != nil {
+			problem = err.Error()
+		} else if c := instance.Status.GetCondition(yagov1alpha1.ConditionHealthy); c != nil && c.Message != "" {
+			problem = c.Message
+		}
+		r.recorder.Eventf(instance, corev1.EventTypeWarning, "RollingBack",
+			"Commit %s did not sync healthy within %s, rolling back to %s: %s",
+			commit, instance.Spec.Rollback.Timeout, instance.Status.LastHealthyCommit, problem)
+		instance.Status.RejectedCommit = commit
+		// The next reconcile fetches again and applies the last healthy commit instead
+		r.checkouts.forget(instance)
+		requeueAfter = retryInterval
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// Objects in remote clusters are not watched, drift is looked for periodically instead
+	if instance.Spec.KubeConfig != nil && (requeueAfter == 0 || driftCheckInterval < requeueAfter) {
+		requeueAfter = driftCheckInterval
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// applyCommit runs the hooks of the commit and applies its objects, then assesses their health.
+// It returns when to requeue to assess their health again.
+func (r *ReconcileYago) applyCommit(
+	instance *yagov1alpha1.Yago,
+	objects []*manifest,
+	hooks map[yagov1alpha1.HookPhase][]*manifest,
+	request string,
+	requeueAfter time.Duration,
+	stats *syncStats,
+	reqLogger logr.Logger) (time.Duration, error) {
+
+	commit := instance.Status.CurrentCommit
+	namespace := hookNamespace(instance)
+	err := r.runHooks(instance, yagov1alpha1.HookPreSync, hooks[yagov1alpha1.HookPreSync], commit, request, namespace, reqLogger)
+	if err == nil {
+		_, err = r.applyWaves(instance, groupWaves(objects), crdKinds(objects), stats, reqLogger)
+	}
+	if isWaiting(err) {
+		return requeueAfter, err
+	} else if err != nil {
+		r.runSyncFailHooks(instance, hooks[yagov1alpha1.HookSyncFail], commit, request, namespace, reqLogger)
+		return requeueAfter, err
+	}
+	reqLogger.Info("End of list")
+	setConflictCondition(instance, objects)
+	inventory, err := r.assessManifests(objects)
+	if err != nil {
+		return requeueAfter, err
+	}
+	instance.Status.Inventory = inventory
+	health, message := overallHealth(inventory)
+	if health != yagov1alpha1.HealthHealthy {
+		instance.Status.SetCondition(yagov1alpha1.ConditionHealthy, corev1.ConditionFalse, string(health), message)
+		// Health is assessed again until every object is healthy
+		if requeueAfter == 0 || healthCheckInterval < requeueAfter {
+			requeueAfter = healthCheckInterval
 		}
+		return requeueAfter, nil
+	}
+	instance.Status.SetCondition(yagov1alpha1.ConditionHealthy, corev1.ConditionTrue, string(health), "")
+	instance.Status.LastHealthyCommit = commit
+	// PostSync hooks wait for every object to be healthy
+	err = r.runHooks(instance, yagov1alpha1.HookPostSync, hooks[yagov1alpha1.HookPostSync], commit, request, namespace, reqLogger)
+	if err != nil && !isWaiting(err) {
+		r.runSyncFailHooks(instance, hooks[yagov1alpha1.HookSyncFail], commit, request, namespace, reqLogger)
+	}
+	return requeueAfter, err
+}
 
-		found := &unstructured.Unstructured{}
-		found.SetGroupVersionKind(schema.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind})
+// applyWaves applies the waves in order. Each wave only starts once the previous one is healthy,
+// until then a waitingError is returned. Objects of the kinds in defined wait for their CRD.
+func (r *ReconcileYago) applyWaves(
+	instance *yagov1alpha1.Yago,
+	waves []*syncWave,
+	defined map[schema.GroupVersionKind]bool,
+	stats *syncStats,
+	reqLogger logr.Logger) (reconcile.Result, error) {
 
-		err = r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: request.Namespace}, found)
-		if err != nil && errors.IsNotFound(err) {
-			unstructured.SetNestedField(unst.Object, request.Namespace, "metadata", "namespace")
-			if err := controllerutil.SetControllerReference(instance, unst, r.scheme); err != nil {
+	for i, wave := range waves {
+		if instance.Status.SyncWave == nil || *instance.Status.SyncWave != wave.number {
+			reqLogger.Info("Starting sync wave", "Wave", wave.number)
+			number := wave.number
+			instance.Status.SyncWave = &number
+			if err := r.client.Status().Update(context.TODO(), instance); err != nil {
 				return reconcile.Result{}, err
 			}
-			if err := r.client.Create(context.TODO(), unst); err != nil {
+		}
+		for _, m := range wave.manifests {
+			// Kinds defined by CRDs of the same commit only become known once the CRDs are served
+			if err := r.waitForMapping(m.gvk, defined); err != nil {
 				return reconcile.Result{}, err
 			}
-		} else if err != nil {
-			return reconcile.Result{}, err
-		} else if !cmp.Equal(found.Object["spec"], unst.Object["spec"]) {
-			if result, err := r.mergeObjects(&request, unst, found, instance.Spec.ForceUpdate, reqLogger); err != nil {
+			if err := r.resolveNamespace(instance, m); err != nil {
+				return reconcile.Result{}, err
+			}
+			if result, err := r.applyManifest(instance, m, stats, reqLogger); err != nil {
 				return result, err
 			}
+			if isCRD(m.gvk) {
+				if err := r.waitForCRDEstablished(m.obj.GetName()); err != nil {
+					return reconcile.Result{}, err
+				}
+			}
+		}
+		// Later waves only start once every object of this wave is ready
+		if i < len(waves)-1 {
+			if err := r.waitForWave(wave); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// applyManifest creates the object of m, or merges it into the existing object if their specs differ
+func (r *ReconcileYago) applyManifest(
+	instance *yagov1alpha1.Yago,
+	m *manifest,
+	stats *syncStats,
+	reqLogger logr.Logger) (reconcile.Result, error) {
+
+	unst := m.obj
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(m.gvk)
+
+	err := r.objects.Get(context.TODO(), types.NamespacedName{Name: unst.GetName(), Namespace: m.namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		unst.SetNamespace(m.namespace)
+		if ownable(instance, m.namespace) {
+			if err := controllerutil.SetControllerReference(instance, unst, r.scheme); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		setTracking(instance, unst)
+		if err := r.objects.Create(context.TODO(), unst); err != nil {
+			return reconcile.Result{}, err
 		}
+		stats.created++
+		return reconcile.Result{}, nil
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+	// Objects managed by another Yago are left alone instead of being fought over
+	other, err := r.managingYago(instance, found)
+	if err != nil {
+		return reconcile.Result{}, err
 	}
+	if other != "" {
+		reqLogger.Info("Skipping object managed by another Yago", "Kind", m.gvk.Kind, "Name", unst.GetName(), "Yago", other)
+		m.conflict = other
+		return reconcile.Result{}, nil
+	}
+	if err := r.adopt(instance, found); err != nil {
+		return reconcile.Result{}, err
+	}
+	// Ignored fields keep their live values, so they neither count as drift nor get patched
+	ignored, err := ignoredPaths(instance, m, found)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	desired := unst.DeepCopy()
+	ignoreDifferences(desired, found, append(ignored, serverPopulatedPaths(m, found)...))
+	if !cmp.Equal(found.Object["spec"], desired.Object["spec"]) {
+		stats.updated++
+		return r.mergeObjects(instance, m, desired, found, reqLogger)
+	}
+	return reconcile.Result{}, nil
 }
 
+// mergeObjects updates a live object to the desired state, by merge patching its spec or, with
+// the Replace sync option, by replacing it. An update rejected for changing immutable fields is
+// carried out by recreating the object from its manifest, if forceUpdate or the Recreate sync
+// option allow it.
 func (r *ReconcileYago) mergeObjects(
-	request *reconcile.Request,
-	unst *unstructured.Unstructured,
+	instance *yagov1alpha1.Yago,
+	m *manifest,
+	desired *unstructured.Unstructured,
 	found *unstructured.Unstructured,
-	forceUpdate bool,
 	reqLogger logr.Logger) (reconcile.Result, error) {
 
-	reqLogger.Info("Merging")
+	reqLogger.Info("Merging", "Kind", m.gvk.Kind, "Name", desired.GetName())
 
-	unstructured.SetNestedField(unst.Object, request.Namespace, "metadata", "namespace")
+	desired.SetNamespace(m.namespace)
+	m.obj.SetNamespace(m.namespace)
 
-	name, isNameFound, err := unstructured.NestedString(unst.UnstructuredContent(), "metadata", "name")
-	if !isNameFound {
-		return reconcile.Result{}, err
+	var err error
+	if m.options.replace {
+		err = r.replaceObject(instance, desired, found)
+	} else {
+		constPatch, patchErr := createSpecPatch(found, desired)
+		if patchErr != nil {
+			return reconcile.Result{}, patchErr
+		}
+		err = r.objects.Patch(context.TODO(), found.DeepCopy(), constPatch)
 	}
-
-	constPatch, err := createSpecPatch(found, unst)
-	if err != nil {
+	if err == nil || !immutableFieldsChanged(err) {
 		return reconcile.Result{}, err
 	}
-	//Try to patch object
-	if err := r.client.Patch(context.TODO(), found.DeepCopy(), constPatch); err != nil {
-		//If object cannot be patched, and it's because it has immutable fields, recreate object
-		if err.(*errors.StatusError).ErrStatus.Code == 422 &&
-			strings.Contains(err.(*errors.StatusError).ErrStatus.Message, "immutable") &&
-			forceUpdate {
-			reqLogger.Info("ForceUpdate is true")
-			//Delete object
-			if err := r.client.Delete(context.TODO(), found); err != nil {
-				return reconcile.Result{}, err
-			}
-			//Wait for object to be deleted
-			if err := wait.Poll(retryInterval, timeout, func() (done bool, err error) {
-				getErr := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: request.Namespace}, found)
-				if getErr != nil {
-					if errors.IsNotFound(getErr) {
-						return true, nil
-					}
-					return false, getErr
-				}
-				return false, nil
-			}); err != nil {
-				return reconcile.Result{}, err
-			}
-			//Create object again
-			reqLogger.Info("Merge-recreate")
-			if err := r.client.Create(context.TODO(), unst); err != nil {
-				return reconcile.Result{}, err
-			}
-			return reconcile.Result{}, nil
+	if !m.options.recreateAllowed(instance.Spec.ForceUpdate) {
+		if m.options.recreate == "never" {
+			return reconcile.Result{}, fmt.Errorf("%s %s changes immutable fields and its sync options forbid recreating it: %v",
+				m.gvk.Kind, desired.GetName(), err)
 		}
-		reqLogger.Info("ForceUpdate is false")
+		return reconcile.Result{}, fmt.Errorf("%s %s changes immutable fields, recreating it requires forceUpdate or the Recreate=true sync option: %v",
+			m.gvk.Kind, desired.GetName(), err)
 	}
-	return reconcile.Result{}, err
+	reqLogger.Info("Recreating object to change immutable fields", "Kind", m.gvk.Kind, "Name", desired.GetName())
+	return reconcile.Result{}, r.recreateObject(instance, m, found)
 }
 
 func createSpecPatch(found *unstructured.Unstructured, unst *unstructured.Unstructured) (client.Patch, error) {
-	patch := found
+	patch := found.DeepCopy()
 	patch.Object["spec"] = unst.Object["spec"]
 	marshalledPatch, err := json.Marshal(patch)
 	if err != nil {